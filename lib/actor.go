@@ -0,0 +1,309 @@
+package lib
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/nsf/termbox-go"
+)
+
+// wakeRadius is how close the player has to get to a sleeping actor before
+// it notices them.
+const wakeRadius = 7
+
+type (
+	// An Actor is a creature other than the player which lives on a level,
+	// such as a monster.
+	Actor struct {
+		X, Y int
+
+		Health    int
+		MaxHealth int
+		Attack    int
+		Defense   int
+
+		// Experience is how much experience the player gets for killing it,
+		// and Loot is the name of the loot table it drops from.
+		Experience int
+		Loot       string
+
+		Glyph  rune
+		Colour termbox.Attribute
+
+		Effects Effects
+		Awake   bool
+
+		// Boss is set if this is the boss of a boss level. Killing a boss
+		// unlocks the level's trapdoor.
+		Boss bool
+
+		// Phases are the stages an actor goes through as it loses health.
+		// Phase is the index of the current one.
+		Phases []Phase
+		Phase  int
+
+		name string
+	}
+
+	// A Phase is a stage of an actor's behaviour. An actor enters a phase
+	// once its health drops to or below the phase's threshold.
+	Phase struct {
+		Name      string
+		Threshold float64 // the fraction of max health to enter the phase at
+		Colour    termbox.Attribute
+
+		// Ranged actors can attack from a distance, in straight lines.
+		Ranged bool
+
+		// OnEnter is called when the actor moves into the phase.
+		OnEnter func(a *Actor)
+	}
+)
+
+// Name returns the name of the actor, for use in messages.
+func (a *Actor) Name() string {
+	if len(a.Phases) > 0 && a.Phases[a.Phase].Name != "" {
+		return a.name + " (" + a.Phases[a.Phase].Name + ")"
+	}
+
+	return a.name
+}
+
+// Damage hurts the actor, moving it into later phases if its health drops
+// low enough.
+func (a *Actor) Damage(amount int, cause string) {
+	a.Health -= amount
+	a.Awake = true
+
+	if a.Health < 0 {
+		a.Health = 0
+	}
+
+	for a.Phase+1 < len(a.Phases) {
+		next := a.Phases[a.Phase+1]
+		if float64(a.Health) > next.Threshold*float64(a.MaxHealth) {
+			break
+		}
+
+		a.Phase++
+
+		if next.OnEnter != nil {
+			next.OnEnter(a)
+		}
+	}
+}
+
+// Heal heals the actor, up to its maximum health.
+func (a *Actor) Heal(amount int) {
+	a.Health += amount
+
+	if a.Health > a.MaxHealth {
+		a.Health = a.MaxHealth
+	}
+}
+
+// Status returns the status effects active on the actor.
+func (a *Actor) Status() *Effects {
+	return &a.Effects
+}
+
+// Dead checks whether the actor has been killed.
+func (a *Actor) Dead() bool {
+	return a.Health <= 0
+}
+
+// Render renders an Actor to the terminal, assuming the top-left of the
+// map is at (x, y)
+func (a *Actor) Render(x, y int) {
+	colour := a.Colour
+	if len(a.Phases) > 0 && a.Phases[a.Phase].Colour != 0 {
+		colour = a.Phases[a.Phase].Colour
+	}
+
+	drawGlyph(x+a.X*2, y+a.Y, string(a.Glyph)+" ", colour|termbox.AttrBold, termbox.ColorDefault)
+}
+
+// Act makes the actor take its turn. A sleeping actor will wake up if the
+// player comes close, and an awake actor will hunt the player down and
+// attack them.
+func (a *Actor) Act(g *Game) {
+	p := g.Player
+	dist := abs(p.X-a.X) + abs(p.Y-a.Y)
+
+	if !a.Awake {
+		if dist > wakeRadius {
+			return
+		}
+
+		a.Awake = true
+		g.UI.SetMinibuf(fmt.Sprintf("The %s notices you!", a.Name()), time.Second*4)
+	}
+
+	if a.Effects.Has(EffectConfusion) && rand.Float64() < 0.5 {
+		dirs := [][]int{{0, -1}, {1, 0}, {0, 1}, {-1, 0}}
+		dir := dirs[rand.Intn(len(dirs))]
+		a.step(g, dir[0], dir[1])
+		return
+	}
+
+	if dist == 1 {
+		damage := attackDamage(a.Attack, p.Effective().Defense)
+		p.Damage(damage, "the "+a.name)
+		g.UI.SetMinibuf(fmt.Sprintf("The %s hits you for %d damage.", a.Name(), damage), time.Second*4)
+		return
+	}
+
+	if len(a.Phases) > 0 && a.Phases[a.Phase].Ranged && dist <= wakeRadius && g.Level.clearLine(a.X, a.Y, p.X, p.Y) {
+		damage := attackDamage(a.Attack, p.Effective().Defense) / 2
+		g.PlayAnimation(boltAnimation(a.X, a.Y, p.X, p.Y))
+		p.Damage(damage, "a bolt from the "+a.name)
+		g.UI.SetMinibuf(fmt.Sprintf("The %s hurls a bolt at you for %d damage.", a.Name(), damage), time.Second*4)
+		return
+	}
+
+	dx, dy := sign(p.X-a.X), sign(p.Y-a.Y)
+
+	if abs(p.X-a.X) >= abs(p.Y-a.Y) {
+		if !a.step(g, dx, 0) {
+			a.step(g, 0, dy)
+		}
+	} else {
+		if !a.step(g, 0, dy) {
+			a.step(g, dx, 0)
+		}
+	}
+}
+
+// step moves the actor (dx, dy) units if the destination is free, and
+// returns whether it moved.
+func (a *Actor) step(g *Game, dx, dy int) bool {
+	if dx == 0 && dy == 0 {
+		return false
+	}
+
+	nx, ny := a.X+dx, a.Y+dy
+
+	if !g.Level.At(nx, ny).Passable() || g.Level.ActorAt(nx, ny) != nil || (g.Player.X == nx && g.Player.Y == ny) {
+		return false
+	}
+
+	a.X, a.Y = nx, ny
+	return true
+}
+
+// ActorAt returns the actor standing at (x, y), or nil if there isn't one.
+func (m *Map) ActorAt(x, y int) *Actor {
+	for _, a := range m.Actors {
+		if a.X == x && a.Y == y {
+			return a
+		}
+	}
+
+	return nil
+}
+
+// clearLine checks whether (x1, y1) and (x2, y2) are in a straight
+// horizontal or vertical line, with only passable tiles between them.
+func (m *Map) clearLine(x1, y1, x2, y2 int) bool {
+	if x1 != x2 && y1 != y2 {
+		return false
+	}
+
+	dx, dy := sign(x2-x1), sign(y2-y1)
+
+	for x, y := x1+dx, y1+dy; x != x2 || y != y2; x, y = x+dx, y+dy {
+		if !m.At(x, y).Passable() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// AttackActor makes the player attack an actor. If they have a weapon
+// equipped, its modifiers get a chance to change the attack.
+func (p *Player) AttackActor(a *Actor) {
+	e := &UseEvent{
+		Game:   p.Game,
+		Target: a,
+		Damage: attackDamage(p.Effective().Attack, a.Defense),
+	}
+
+	if weapon := p.Equipment["weapon"]; weapon != nil {
+		e.Item = weapon
+		useItem(e)
+	}
+
+	a.Damage(e.Damage, "you")
+	p.Game.PlayAnimation(flashAnimation(a.X, a.Y))
+
+	msg := fmt.Sprintf("You hit the %s for %d damage.", a.Name(), e.Damage)
+	if e.Message != "" {
+		msg += " " + e.Message
+	}
+
+	p.Game.UI.SetMinibuf(msg, time.Second*4)
+	p.Game.reap()
+}
+
+// reap removes any dead actors from the current level, rewarding the
+// player for killing them.
+func (g *Game) reap() {
+	alive := g.Level.Actors[:0]
+
+	for _, a := range g.Level.Actors {
+		if !a.Dead() {
+			alive = append(alive, a)
+			continue
+		}
+
+		msg := fmt.Sprintf("You killed the %s!", a.name)
+
+		if a.Boss {
+			g.Level.Unlock()
+			msg += " You hear the trapdoor click open."
+		}
+
+		g.Player.GainExperience(a.Experience)
+
+		if a.Loot != "" {
+			msg += " " + g.giveLoot("on its body", a.X, a.Y, RollLoot(g.Known, a.Loot, g.Level.Depth))
+		}
+
+		g.UI.SetMinibuf(msg, time.Second*4)
+	}
+
+	g.Level.Actors = alive
+}
+
+// attackDamage works out how much damage an attack does, given the attack
+// stat of the attacker and the defense stat of the defender. An attack
+// always does at least one damage.
+func attackDamage(attack, defense int) int {
+	damage := attack*2 + rand.Intn(attack+1) - defense
+	if damage < 1 {
+		return 1
+	}
+
+	return damage
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	default:
+		return 0
+	}
+}
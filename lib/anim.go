@@ -0,0 +1,214 @@
+package lib
+
+import (
+	"image"
+	"time"
+)
+
+type (
+	// An Animation is a short sequence of frames drawn over the map, such as
+	// a bolt flying through the air. Animations are queued up and played one
+	// after another.
+	Animation struct {
+		Frames [][]animCell
+
+		// Blocking animations stop the player from doing anything until
+		// they've finished, or been skipped.
+		Blocking bool
+
+		// OnDone is called when the animation finishes or is skipped.
+		OnDone func()
+
+		// start is when the animation started playing, or the zero time if
+		// it's still waiting in the queue.
+		start time.Time
+	}
+
+	// An animCell is something drawn on a single tile in one frame of an
+	// animation. It's either a role from the theme, which can have a glyph
+	// for each frame, or a flash which reverses whatever's on the tile.
+	animCell struct {
+		X, Y  int
+		Role  string
+		Flash bool
+	}
+)
+
+// FrameDuration returns how long each frame of an animation is shown for.
+func FrameDuration() time.Duration {
+	if Conf.AnimationFPS <= 0 {
+		return time.Second
+	}
+
+	return time.Second / time.Duration(Conf.AnimationFPS)
+}
+
+// PlayAnimation adds an animation to the end of the queue. If animations are
+// turned off, it finishes straight away.
+func (g *Game) PlayAnimation(a *Animation) {
+	if Conf.AnimationFPS <= 0 || len(a.Frames) == 0 {
+		if a.OnDone != nil {
+			a.OnDone()
+		}
+
+		return
+	}
+
+	g.Animations = append(g.Animations, a)
+}
+
+// Animating checks whether there are any animations playing.
+func (g *Game) Animating() bool {
+	return len(g.Animations) > 0
+}
+
+// AnimationBlocking checks whether the player has to wait for an animation
+// to finish before they can do anything.
+func (g *Game) AnimationBlocking() bool {
+	for _, a := range g.Animations {
+		if a.Blocking {
+			return true
+		}
+	}
+
+	return false
+}
+
+// UpdateAnimations takes the animations which have finished out of the
+// queue, and starts the next one playing.
+func (g *Game) UpdateAnimations() {
+	for len(g.Animations) > 0 {
+		a := g.Animations[0]
+
+		if a.start.IsZero() {
+			a.start = time.Now()
+		}
+
+		if time.Since(a.start) < FrameDuration()*time.Duration(len(a.Frames)) {
+			return
+		}
+
+		g.Animations = g.Animations[1:]
+
+		if a.OnDone != nil {
+			a.OnDone()
+		}
+	}
+}
+
+// SkipAnimations finishes every animation in the queue straight away.
+func (g *Game) SkipAnimations() {
+	for len(g.Animations) > 0 {
+		a := g.Animations[0]
+		g.Animations = g.Animations[1:]
+
+		if a.OnDone != nil {
+			a.OnDone()
+		}
+	}
+}
+
+// renderAnimation draws the current frame of the animation which is
+// playing, with the top-left of the map at (x, y). Only tiles inside view
+// are drawn.
+func (g *Game) renderAnimation(x, y int, view image.Rectangle) {
+	if len(g.Animations) == 0 {
+		return
+	}
+
+	var (
+		a     = g.Animations[0]
+		frame = 0
+	)
+
+	if !a.start.IsZero() {
+		frame = int(time.Since(a.start) / FrameDuration())
+	}
+
+	if frame >= len(a.Frames) {
+		return
+	}
+
+	for _, c := range a.Frames[frame] {
+		if !image.Pt(c.X, c.Y).In(view) {
+			continue
+		}
+
+		sx, sy := x+c.X*2, y+c.Y
+
+		if c.Flash {
+			highlight(sx, sy)
+			highlight(sx+1, sy)
+			continue
+		}
+
+		// A role can have a different glyph for each frame.
+		role := CurrentTheme.Role(c.Role)
+		if glyphs := []rune(role.Glyph); len(glyphs) > 0 {
+			role.Glyph = string(glyphs[frame*len(glyphs)/len(a.Frames)]) + " "
+		}
+
+		role.Render(sx, sy)
+	}
+}
+
+// boltAnimation is a bolt flying in a straight line from (x1, y1) to
+// (x2, y2).
+func boltAnimation(x1, y1, x2, y2 int) *Animation {
+	var (
+		a      = &Animation{Blocking: true}
+		dx, dy = sign(x2 - x1), sign(y2 - y1)
+	)
+
+	for x, y := x1+dx, y1+dy; x != x2 || y != y2; x, y = x+dx, y+dy {
+		a.Frames = append(a.Frames, []animCell{{X: x, Y: y, Role: "anim-bolt"}})
+	}
+
+	return a
+}
+
+// flashAnimation makes the tile at (x, y) flash, to show that whoever's
+// standing there has been hurt.
+func flashAnimation(x, y int) *Animation {
+	a := &Animation{}
+
+	for i := 0; i < 6; i++ {
+		if i%2 == 0 {
+			a.Frames = append(a.Frames, []animCell{{X: x, Y: y, Flash: true}})
+		} else {
+			a.Frames = append(a.Frames, nil)
+		}
+	}
+
+	return a
+}
+
+// sparkleAnimation is a ring of sparkles spreading out from a chest at
+// (x, y) as it's opened.
+func sparkleAnimation(x, y int) *Animation {
+	a := &Animation{}
+
+	for r := 1; r <= 3; r++ {
+		frame := []animCell{}
+
+		for _, d := range []image.Point{{0, -1}, {1, -1}, {1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}} {
+			frame = append(frame, animCell{X: x + d.X*r, Y: y + d.Y*r, Role: "anim-sparkle"})
+		}
+
+		a.Frames = append(a.Frames, frame, frame)
+	}
+
+	return a
+}
+
+// fallAnimation is the player dropping through the trapdoor at (x, y).
+// The next level is entered once it's finished.
+func fallAnimation(x, y int, onDone func()) *Animation {
+	a := &Animation{Blocking: true, OnDone: onDone}
+
+	for i := 0; i < 9; i++ {
+		a.Frames = append(a.Frames, []animCell{{X: x, Y: y, Role: "anim-fall"}})
+	}
+
+	return a
+}
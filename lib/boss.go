@@ -0,0 +1,208 @@
+package lib
+
+import (
+	"fmt"
+	"image"
+	"time"
+
+	"github.com/nsf/termbox-go"
+)
+
+var bossNames = []string{
+	"Warden of the Deep",
+	"Grave Colossus",
+	"Hollow King",
+	"Mother of Rats",
+	"Lich Cartographer",
+}
+
+// isBossLevel checks whether the level at the given depth should be a boss
+// level.
+func isBossLevel(depth int) bool {
+	return Conf.BossInterval > 0 && depth%Conf.BossInterval == 0
+}
+
+// checkArena makes sure the boss arena, and the antechamber below it, fit
+// on the map with room for the walls around them.
+func checkArena() error {
+	if Conf.BossInterval <= 0 {
+		return nil
+	}
+
+	r := Conf.ArenaRadius
+
+	if r <= 0 {
+		return fmt.Errorf("arena-radius must be more than 0")
+	}
+
+	if h := 2*r + 14; Conf.MapHeight < h {
+		return fmt.Errorf("an arena-radius of %d needs a map-height of at least %d", r, h)
+	}
+
+	if w := 2*r + 4; Conf.MapWidth < w {
+		return fmt.Errorf("an arena-radius of %d needs a map-width of at least %d", r, w)
+	}
+
+	return nil
+}
+
+// MakeBossMap generates a boss level. Instead of the usual rooms, it has a
+// single large arena with some pillars, and a small antechamber below it
+// for the player to enter in. The trapdoor is locked until the boss has
+// been killed.
+func MakeBossMap(depth int) *Map {
+	m := &Map{
+		Depth: depth,
+		Tiles: make([][]Tile, Conf.MapHeight),
+	}
+
+	for y := range m.Tiles {
+		m.Tiles[y] = make([]Tile, Conf.MapWidth)
+
+		for x := range m.Tiles[y] {
+			m.Tiles[y][x] = &OutsideTile{}
+		}
+	}
+
+	var (
+		r  = Conf.ArenaRadius
+		cx = Conf.MapWidth / 2
+		cy = r + 2
+
+		door = image.Point{X: cx, Y: cy + r + 6}
+	)
+
+	m.fill(cx-r, cy-r, cx+r, cy+r, func() Tile { return &FloorTile{} })
+	m.fill(cx-1, cy+r, cx+1, door.Y, func() Tile { return &FloorTile{} })
+	m.fill(door.X-3, door.Y, door.X+3, door.Y+4, func() Tile { return &FloorTile{} })
+
+	for _, p := range []image.Point{{X: -1, Y: -1}, {X: 1, Y: -1}, {X: -1, Y: 1}, {X: 1, Y: 1}} {
+		px, py := cx+p.X*r/2, cy+p.Y*r/2
+		m.fill(px-1, py-1, px, py, func() Tile { return &WallTile{} })
+	}
+
+	for y := 0; y < m.Height(); y++ {
+		for x := 0; x < m.Width(); x++ {
+			if m.At(x, y).Type() == TileOutside && m.neighbours(x, y, TileFloor) > 0 {
+				m.Set(x, y, &WallTile{})
+			}
+		}
+	}
+
+	m.Set(cx, cy-r+1, &TrapdoorTile{Locked: true})
+	m.Set(cx+2, cy-r+1, &ChestTile{Rare: true})
+
+	m.Actors = append(m.Actors, newBoss(depth, cx, cy-2))
+	m.Entrance = &image.Point{X: door.X, Y: door.Y + 2}
+
+	return m
+}
+
+// fill sets every tile in the rectangle between (x1, y1) and (x2, y2),
+// inclusive, to a new tile made by the given function.
+func (m *Map) fill(x1, y1, x2, y2 int, tile func() Tile) {
+	for y := y1; y <= y2; y++ {
+		for x := x1; x <= x2; x++ {
+			if x > 0 && y > 0 && x < m.Width()-1 && y < m.Height()-1 {
+				m.Set(x, y, tile())
+			}
+		}
+	}
+}
+
+// newBoss makes the boss for the given depth. Bosses get stronger the
+// deeper they are, and go through three phases: first they just fight,
+// then they get enraged and speed up, and finally they get desperate,
+// regenerating and throwing bolts from a distance.
+func newBoss(depth, x, y int) *Actor {
+	var (
+		n     = depth / Conf.BossInterval
+		name  = bossNames[(n-1)%len(bossNames)]
+		scale = 1 + n/len(bossNames)
+	)
+
+	health := (40 + depth*10) * scale
+
+	return &Actor{
+		X:          x,
+		Y:          y,
+		Health:     health,
+		MaxHealth:  health,
+		Attack:     (2 + depth/2) * scale,
+		Defense:    depth / 5 * scale,
+		Experience: 25 * depth,
+		Loot:       "boss",
+		Glyph:      'Ω',
+		Colour:     termbox.ColorRed,
+		Boss:       true,
+		name:       name,
+
+		Phases: []Phase{
+			{
+				Threshold: 1,
+			},
+			{
+				Name:      "enraged",
+				Threshold: 0.66,
+				Colour:    termbox.ColorYellow,
+				OnEnter: func(a *Actor) {
+					a.Effects.Add(EffectHaste, 10, 0)
+				},
+			},
+			{
+				Name:      "desperate",
+				Threshold: 0.33,
+				Colour:    termbox.ColorMagenta,
+				Ranged:    true,
+				OnEnter: func(a *Actor) {
+					a.Effects.Add(EffectRegeneration, 8, 1+depth/5)
+				},
+			},
+		},
+	}
+}
+
+// Unlock unlocks every locked trapdoor on the level.
+func (m *Map) Unlock() {
+	for _, row := range m.Tiles {
+		for _, tile := range row {
+			if t, ok := tile.(*TrapdoorTile); ok {
+				t.Locked = false
+			}
+		}
+	}
+}
+
+// Boss returns the boss of the level, or nil if it doesn't have one (or
+// it's been killed).
+func (m *Map) Boss() *Actor {
+	for _, a := range m.Actors {
+		if a.Boss {
+			return a
+		}
+	}
+
+	return nil
+}
+
+// renderBossBar renders the health of the level's boss, if it's woken up.
+func (u *UI) renderBossBar(x, y int) {
+	boss := u.Game.Level.Boss()
+	if boss == nil || !boss.Awake {
+		return
+	}
+
+	writeText(x, y, -1, "^r%s^!", termbox.ColorDefault, termbox.ColorDefault, boss.Name())
+	writeText(x, y+1, -1, "%s %d/%d", termbox.ColorDefault, termbox.ColorDefault,
+		progressBar(boss.Health, boss.MaxHealth, 20), boss.Health, boss.MaxHealth)
+}
+
+// bossWarning is shown when the player walks onto a locked trapdoor.
+func bossWarning(g *Game) {
+	boss := g.Level.Boss()
+	if boss == nil {
+		return
+	}
+
+	g.UI.SetMinibuf(fmt.Sprintf("The trapdoor is locked shut. Perhaps the %s has the key...", boss.name), time.Second*4)
+}
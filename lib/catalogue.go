@@ -0,0 +1,400 @@
+package lib
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"sort"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Items is the global item catalogue
+var Items *Catalogue
+
+type (
+	// A Catalogue stores every kind, type, rarity and modifier an item can
+	// have, and is created from the item catalogue file.
+	Catalogue struct {
+		Kinds     map[string]ItemKind
+		Types     map[string]ItemType
+		Rarities  []ItemRarity // from most to least common
+		Modifiers map[string]ItemModifier
+
+		// ModifierAppearances are what hidden modifiers can look like before
+		// they're identified.
+		ModifierAppearances []string
+
+		// typeNames and modifierNames are the keys of Types and Modifiers,
+		// sorted so that random choices from them are reproducible.
+		typeNames     []string
+		modifierNames []string
+	}
+
+	// catalogueFile is the layout of the item catalogue file.
+	catalogueFile struct {
+		Kinds map[string]struct {
+			Quality      int      `yaml:"quality"`
+			Slot         string   `yaml:"slot"`
+			Appearances  []string `yaml:"appearances"`
+			Unidentified string   `yaml:"unidentified"`
+		} `yaml:"kinds"`
+
+		Rarities []struct {
+			Name    string `yaml:"name"`
+			Quality int    `yaml:"quality"`
+			Weight  int    `yaml:"weight"`
+			Fg      string `yaml:"fg"`
+			Bg      string `yaml:"bg"`
+		} `yaml:"rarities"`
+
+		Types map[string]struct {
+			Kind        string   `yaml:"kind"`
+			Quality     int      `yaml:"quality"`
+			Glyph       string   `yaml:"glyph"`
+			Description string   `yaml:"description"`
+			Stats       Stats    `yaml:",inline"`
+			Use         *ItemUse `yaml:"use"`
+		} `yaml:"types"`
+
+		Modifiers map[string]struct {
+			Quality int      `yaml:"quality"`
+			Chance  float64  `yaml:"chance"`
+			Kinds   []string `yaml:"kinds"`
+			Hooks   string   `yaml:"hooks"`
+			Hidden  bool     `yaml:"hidden"`
+			Stats   Stats    `yaml:",inline"`
+		} `yaml:"modifiers"`
+
+		ModifierAppearances []string `yaml:"modifier-appearances"`
+	}
+)
+
+// LoadCatalogue creates a new Catalogue instance from the given file.
+func LoadCatalogue(filename string) (*Catalogue, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	bytes, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw catalogueFile
+	if err = yaml.Unmarshal(bytes, &raw); err != nil {
+		return nil, err
+	}
+
+	c := &Catalogue{
+		Kinds:               make(map[string]ItemKind),
+		Types:               make(map[string]ItemType),
+		Modifiers:           make(map[string]ItemModifier),
+		ModifierAppearances: raw.ModifierAppearances,
+	}
+
+	for name, k := range raw.Kinds {
+		if k.Slot != "" && !validSlot(k.Slot) {
+			return nil, fmt.Errorf("kind %s: unknown equipment slot %q", name, k.Slot)
+		}
+
+		if k.Unidentified == "" {
+			k.Unidentified = "%s " + name
+		}
+
+		c.Kinds[name] = ItemKind{
+			Name:         name,
+			Quality:      k.Quality,
+			Slot:         k.Slot,
+			Appearances:  k.Appearances,
+			Unidentified: k.Unidentified,
+		}
+	}
+
+	for _, r := range raw.Rarities {
+		fg, err := parseColour(r.Fg)
+		if err != nil {
+			return nil, fmt.Errorf("rarity %s: %v", r.Name, err)
+		}
+
+		bg, err := parseColour(r.Bg)
+		if err != nil {
+			return nil, fmt.Errorf("rarity %s: %v", r.Name, err)
+		}
+
+		c.Rarities = append(c.Rarities, ItemRarity{
+			Name:    r.Name,
+			Fg:      fg,
+			Bg:      bg,
+			Quality: r.Quality,
+			Weight:  r.Weight,
+		})
+	}
+
+	if len(c.Rarities) == 0 {
+		return nil, fmt.Errorf("%s: no rarities defined", filename)
+	}
+
+	for name, t := range raw.Types {
+		kind, ok := c.Kinds[t.Kind]
+		if !ok {
+			return nil, fmt.Errorf("type %s: unknown kind %q", name, t.Kind)
+		}
+
+		if t.Use != nil && t.Use.Effect != "" {
+			if _, ok := effectByName(t.Use.Effect); !ok {
+				return nil, fmt.Errorf("type %s: unknown effect %q", name, t.Use.Effect)
+			}
+		}
+
+		if t.Use != nil && t.Use.Cure != "" {
+			if _, ok := effectByName(t.Use.Cure); !ok {
+				return nil, fmt.Errorf("type %s: unknown effect %q", name, t.Use.Cure)
+			}
+		}
+
+		c.Types[name] = ItemType{
+			Name:        name,
+			Kind:        kind,
+			Quality:     t.Quality,
+			Glyph:       t.Glyph,
+			Description: t.Description,
+			Stats:       t.Stats,
+			Use:         t.Use,
+		}
+
+		c.typeNames = append(c.typeNames, name)
+	}
+
+	for name, m := range raw.Modifiers {
+		for _, kind := range m.Kinds {
+			if _, ok := c.Kinds[kind]; !ok {
+				return nil, fmt.Errorf("modifier %s: unknown kind %q", name, kind)
+			}
+		}
+
+		if m.Hooks == "" {
+			m.Hooks = name
+		} else if _, ok := builtinModifiers[m.Hooks]; !ok {
+			return nil, fmt.Errorf("modifier %s: unknown hooks %q", name, m.Hooks)
+		}
+
+		hooks := builtinModifiers[m.Hooks]
+
+		c.Modifiers[name] = ItemModifier{
+			Name:    name,
+			Quality: m.Quality,
+			Stats:   m.Stats,
+			Chance:  m.Chance,
+			Kinds:   m.Kinds,
+			Hidden:  m.Hidden,
+			Modify:  hooks.Modify,
+			OnUse:   hooks.OnUse,
+			OnTick:  hooks.OnTick,
+		}
+
+		c.modifierNames = append(c.modifierNames, name)
+	}
+
+	sort.Strings(c.typeNames)
+	sort.Strings(c.modifierNames)
+
+	if err := c.checkAppearances(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// checkAppearances makes sure there are enough appearances for every
+// unidentified type and hidden modifier to look different.
+func (c *Catalogue) checkAppearances() error {
+	types := make(map[string]int)
+	for _, t := range c.Types {
+		types[t.Kind.Name]++
+	}
+
+	for name, kind := range c.Kinds {
+		if n := len(kind.Appearances); n > 0 && n < types[name] {
+			return fmt.Errorf("kind %s: %d appearances for %d types", name, n, types[name])
+		}
+	}
+
+	hidden := 0
+	for _, m := range c.Modifiers {
+		if m.Hidden {
+			hidden++
+		}
+	}
+
+	if hidden > len(c.ModifierAppearances) {
+		return fmt.Errorf("%d modifier appearances for %d hidden modifiers", len(c.ModifierAppearances), hidden)
+	}
+
+	return nil
+}
+
+// NewItem makes an item from the names of its type, rarity and modifiers.
+// Items are made for a run, and are called by what the player knows about
+// them in k.
+func (c *Catalogue) NewItem(k *Knowledge, typ, rarity string, mods ...string) (Item, error) {
+	t, ok := c.Types[typ]
+	if !ok {
+		return nil, fmt.Errorf("unknown item type %q", typ)
+	}
+
+	var (
+		r      ItemRarity
+		chosen []ItemModifier
+	)
+
+	for _, rr := range c.Rarities {
+		if rr.Name == rarity {
+			r = rr
+		}
+	}
+
+	if r.Name == "" {
+		return nil, fmt.Errorf("unknown item rarity %q", rarity)
+	}
+
+	for _, name := range mods {
+		m, ok := c.Modifiers[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown item modifier %q", name)
+		}
+
+		chosen = append(chosen, m)
+	}
+
+	return newBasicItem(k, t, r, chosen, 1), nil
+}
+
+// RandomItem makes an item of a random type and rarity, possibly with some
+// modifiers, for a level at the given depth.
+func (c *Catalogue) RandomItem(k *Knowledge, depth int) Item {
+	return c.RandomItemOf(k, "", "", depth)
+}
+
+// RandomItemOf makes a random item of the given kind, with a rarity no lower
+// than minRarity. An empty kind means any kind, and an empty minRarity means
+// any rarity.
+func (c *Catalogue) RandomItemOf(k *Knowledge, kind, minRarity string, depth int) Item {
+	names := []string{}
+
+	for _, name := range c.typeNames {
+		if kind == "" || c.Types[name].Kind.Name == kind {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		names = c.typeNames
+	}
+
+	item, _ := c.NewItemAt(k, names[rand.Intn(len(names))], minRarity, depth)
+	return item
+}
+
+// NewItemAt makes an item of the given type, with a random rarity (no lower
+// than minRarity) and random modifiers, for a level at the given depth.
+func (c *Catalogue) NewItemAt(k *Knowledge, typ, minRarity string, depth int) (Item, error) {
+	t, ok := c.Types[typ]
+	if !ok {
+		return nil, fmt.Errorf("unknown item type %q", typ)
+	}
+
+	rarity := c.RandomRarity(depth)
+	if min := c.rarityIndex(minRarity); c.rarityIndex(rarity.Name) < min {
+		rarity = c.Rarities[min]
+	}
+
+	return newBasicItem(k, t, rarity, c.randomModifiers(t.Kind), depth), nil
+}
+
+// rarityIndex returns the position of the named rarity in the list of
+// rarities, or 0 if there isn't one with that name.
+func (c *Catalogue) rarityIndex(name string) int {
+	for i, r := range c.Rarities {
+		if r.Name == name {
+			return i
+		}
+	}
+
+	return 0
+}
+
+// hasRarity checks whether there's a rarity with the given name.
+func (c *Catalogue) hasRarity(name string) bool {
+	for _, r := range c.Rarities {
+		if r.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RandomRarity picks a rarity, weighted by how common each one is. The
+// deeper the level, the more likely the rarer ones are.
+func (c *Catalogue) RandomRarity(depth int) ItemRarity {
+	var (
+		weights = make([]float64, len(c.Rarities))
+		total   = 0.0
+	)
+
+	for i, r := range c.Rarities {
+		weights[i] = float64(r.Weight) * (1 + Conf.RarityDepthScale*float64(i*(depth-1)))
+		total += weights[i]
+	}
+
+	if total <= 0 {
+		return c.Rarities[0]
+	}
+
+	n := rand.Float64() * total
+
+	for i, r := range c.Rarities {
+		if n < weights[i] {
+			return r
+		}
+
+		n -= weights[i]
+	}
+
+	return c.Rarities[len(c.Rarities)-1]
+}
+
+// randomModifiers picks some modifiers which can go on an item of the given
+// kind. Each modifier has its own chance of being picked.
+func (c *Catalogue) randomModifiers(kind ItemKind) []ItemModifier {
+	mods := []ItemModifier{}
+
+	for _, name := range c.modifierNames {
+		m := c.Modifiers[name]
+
+		if m.canModify(kind) && rand.Float64() < m.Chance {
+			mods = append(mods, m)
+		}
+	}
+
+	return mods
+}
+
+// canModify checks whether a modifier can be put on items of a kind.
+func (m ItemModifier) canModify(kind ItemKind) bool {
+	if len(m.Kinds) == 0 {
+		return true
+	}
+
+	for _, k := range m.Kinds {
+		if k == kind.Name {
+			return true
+		}
+	}
+
+	return false
+}
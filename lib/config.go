@@ -1,6 +1,7 @@
 package lib
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 
@@ -13,18 +14,60 @@ var Conf *Config
 // The Config says how certain things should behave, and is created from the
 // config file.
 type Config struct {
-	MapWidth                   int     `yaml:"map-width"`
-	MapHeight                  int     `yaml:"map-height"`
-	GridSpacing                int     `yaml:"grid-spacing"`
-	RoadWidth                  int     `yaml:"road-width"`
-	RoomWidth                  int     `yaml:"room-radius"`
-	RoomWidthVariance          float64 `yaml:"room-radius-variance"`
-	NodeChance                 float64 `yaml:"node-chance"`
-	RoomProbabilityCoefficient float64 `yaml:"room-prob-coefficient"`
-	NumThreads                 int     `yaml:"num-threads"`
-	BoxChance                  float64 `yaml:"box-chance"`
-	ChestChance                float64 `yaml:"chest-chance"`
-	NumMerchants               int     `yaml:"num-merchants"`
+	MapWidth                   int      `yaml:"map-width"`
+	MapHeight                  int      `yaml:"map-height"`
+	GridSpacing                int      `yaml:"grid-spacing"`
+	RoadWidth                  int      `yaml:"road-width"`
+	RoomWidth                  int      `yaml:"room-radius"`
+	RoomWidthVariance          float64  `yaml:"room-radius-variance"`
+	NodeChance                 float64  `yaml:"node-chance"`
+	RoomProbabilityCoefficient float64  `yaml:"room-prob-coefficient"`
+	NumThreads                 int      `yaml:"num-threads"`
+	BoxChance                  float64  `yaml:"box-chance"`
+	ChestChance                float64  `yaml:"chest-chance"`
+	NumMerchants               int      `yaml:"num-merchants"`
+	LevelXPBase                int      `yaml:"level-xp-base"`
+	LevelXPGrowth              float64  `yaml:"level-xp-growth"`
+	LevelUpPoints              int      `yaml:"level-up-points"`
+	LevelUpHealth              int      `yaml:"level-up-health"`
+	TrapChance                 float64  `yaml:"trap-chance"`
+	TrapDepthScale             float64  `yaml:"trap-depth-scale"`
+	SearchChance               float64  `yaml:"search-chance"`
+	DisarmChance               float64  `yaml:"disarm-chance"`
+	BossInterval               int      `yaml:"boss-interval"`
+	ArenaRadius                int      `yaml:"arena-radius"`
+	FogOfWar                   bool     `yaml:"fog-of-war"`
+	ViewRadius                 int      `yaml:"view-radius"`
+	MorgueDir                  string   `yaml:"morgue-dir"`
+	MorgueMessages             int      `yaml:"morgue-messages"`
+	ItemCatalogue              string   `yaml:"item-catalogue"`
+	ThemeDir                   string   `yaml:"theme-dir"`
+	Theme                      string   `yaml:"theme"`
+	Colours                    string   `yaml:"colours"`
+	Sidebar                    string   `yaml:"sidebar"`
+	ASCII                      bool     `yaml:"ascii"`
+	Narrate                    bool     `yaml:"narrate"`
+	NarrationFile              string   `yaml:"narration-file"`
+	AnimationFPS               int      `yaml:"animation-fps"`
+	InventorySize              int      `yaml:"inventory-size"`
+	StartingItems              []string `yaml:"starting-items"`
+	PricePerQuality            float64  `yaml:"price-per-quality"`
+	PriceExponent              float64  `yaml:"price-exponent"`
+	SellMarkdown               float64  `yaml:"sell-markdown"`
+	RarityDepthScale           float64  `yaml:"rarity-depth-scale"`
+	MerchantStock              int      `yaml:"merchant-stock"`
+	MerchantFood               int      `yaml:"merchant-food"`
+	HaggleChance               float64  `yaml:"haggle-chance"`
+	HaggleDiscount             float64  `yaml:"haggle-discount"`
+	IdentifyPrice              int      `yaml:"identify-price"`
+	InspectChance              float64  `yaml:"inspect-chance"`
+	MaxSatiety                 int      `yaml:"max-satiety"`
+	HungryAt                   int      `yaml:"hungry-at"`
+	WeakAt                     int      `yaml:"weak-at"`
+	StarveDamage               int      `yaml:"starve-damage"`
+
+	Keys       map[string][]string  `yaml:"keys"`
+	LootTables map[string]LootTable `yaml:"loot-tables"`
 }
 
 // LoadConfig creates a new Config instance from the given file.
@@ -49,11 +92,43 @@ func LoadConfig(filename string) (*Config, error) {
 	return cfg, nil
 }
 
-func init() {
-	c, err := LoadConfig("cfg.yaml")
+// Setup loads the config file at path, and then everything which depends
+// on it: the item catalogue, the theme and the keymap. It returns an error
+// if any of them are wrong. It has to be called before anything else in the
+// package is used.
+func Setup(path string) error {
+	c, err := LoadConfig(path)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	Conf = c
+
+	if Items, err = LoadCatalogue(Conf.ItemCatalogue); err != nil {
+		return fmt.Errorf("loading the item catalogue: %v", err)
+	}
+
+	// The loot tables are in the config, but they can only be checked once
+	// the catalogue they refer to has been loaded.
+	if err = Items.checkLootTables(Conf.LootTables); err != nil {
+		return err
+	}
+
+	if err = checkStartingItems(); err != nil {
+		return err
+	}
+
+	if err = checkExperience(); err != nil {
+		return err
+	}
+
+	if err = checkArena(); err != nil {
+		return err
+	}
+
+	if err = loadCurrentTheme(); err != nil {
+		return fmt.Errorf("loading the theme: %v", err)
+	}
+
+	return buildKeymap()
 }
@@ -0,0 +1,350 @@
+package lib
+
+import (
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+// The widest a dialog can be, and how far it's kept from the edges of the
+// screen.
+const (
+	dialogMaxWidth = 60
+	dialogMargin   = 2
+)
+
+// A Dialog is a box drawn over the game which takes all of the player's
+// key presses until it closes. Each kind of dialog reports what the player
+// chose through a callback.
+type Dialog interface {
+	// title is shown in the dialog's border.
+	title() string
+
+	// height returns how many rows the dialog's contents take up when
+	// they're width columns wide.
+	height(width int) int
+
+	// render draws the dialog's contents inside r.
+	render(r Rect)
+
+	// handleKey deals with a key press, returning true if it closes the
+	// dialog.
+	handleKey(ch rune, key termbox.Key) bool
+}
+
+type (
+	// A ConfirmDialog asks the player a yes or no question.
+	ConfirmDialog struct {
+		Title, Text string
+
+		// Yes and No say what pressing ENTER and ESC do, e.g. "enter the
+		// next level".
+		Yes, No string
+
+		OnResult func(yes bool)
+	}
+
+	// A MenuDialog lets the player choose one of a list of options. They
+	// can press ESC to choose none of them, in which case OnSelect gets -1.
+	MenuDialog struct {
+		Title    string
+		Options  []string
+		Selected int
+
+		OnSelect func(i int)
+	}
+
+	// An InputDialog asks the player to type something in. If they press
+	// ESC instead, OnSubmit gets false.
+	InputDialog struct {
+		Title, Prompt string
+		Text          string
+		MaxLength     int
+
+		OnSubmit func(text string, ok bool)
+	}
+
+	// A PagedDialog shows some text which might be too long to fit on the
+	// screen, a page at a time.
+	PagedDialog struct {
+		Title, Text string
+		Page        int
+
+		OnClose func()
+
+		// pageHeight is how many rows were shown on each page the last time
+		// it was drawn.
+		pageHeight int
+	}
+)
+
+// OpenDialog opens a dialog on top of any which are already open.
+func (g *Game) OpenDialog(d Dialog) {
+	g.Dialogs = append(g.Dialogs, d)
+}
+
+// Dialog returns the dialog which is taking the player's input, or nil if
+// there isn't one open.
+func (g *Game) Dialog() Dialog {
+	if len(g.Dialogs) == 0 {
+		return nil
+	}
+
+	return g.Dialogs[len(g.Dialogs)-1]
+}
+
+// HandleDialogKey passes a key press to the top dialog, closing it if it's
+// done.
+func (g *Game) HandleDialogKey(ch rune, key termbox.Key) {
+	d := g.Dialog()
+	if d == nil {
+		return
+	}
+
+	// The dialog is taken off the stack first, so that if its callback
+	// opens another dialog, that one isn't closed straight away.
+	g.Dialogs = g.Dialogs[:len(g.Dialogs)-1]
+
+	if !d.handleKey(ch, key) {
+		g.Dialogs = append(g.Dialogs, d)
+	}
+}
+
+// renderDialog draws the top dialog in a box in the middle of the screen.
+func (g *Game) renderDialog() {
+	d := g.Dialog()
+	if d == nil {
+		return
+	}
+
+	var (
+		sw, sh = termbox.Size()
+		w      = minInt(dialogMaxWidth, sw-dialogMargin*2)
+		h      = minInt(d.height(w-4)+2, sh-dialogMargin*2)
+		box    = Rect{(sw - w) / 2, (sh - h) / 2, w, h}
+	)
+
+	drawBox(box, d.title())
+	d.render(Rect{box.X + 2, box.Y + 1, box.W - 4, box.H - 2})
+}
+
+// drawBox clears a rect and draws a border around its edge, with a title
+// in the top of it.
+func drawBox(r Rect, title string) {
+	if r.Empty() {
+		return
+	}
+
+	fg, bg := termbox.ColorDefault, termbox.ColorDefault
+	clearRect(r.X, r.Y, r.W, r.H)
+
+	right, bottom := r.X+r.W-1, r.Y+r.H-1
+
+	for x := r.X; x <= right; x++ {
+		setCell(x, r.Y, '─', fg, bg)
+		setCell(x, bottom, '─', fg, bg)
+	}
+
+	for y := r.Y; y <= bottom; y++ {
+		setCell(r.X, y, '│', fg, bg)
+		setCell(right, y, '│', fg, bg)
+	}
+
+	setCell(r.X, r.Y, '┌', fg, bg)
+	setCell(right, r.Y, '┐', fg, bg)
+	setCell(r.X, bottom, '└', fg, bg)
+	setCell(right, bottom, '┘', fg, bg)
+
+	if title != "" {
+		writeText(r.X+2, r.Y, -1, " ^B%s^! ", fg, bg, title)
+	}
+}
+
+// ConfirmDialog
+
+func (d *ConfirmDialog) title() string { return d.Title }
+
+func (d *ConfirmDialog) height(width int) int {
+	_, h := measureText(width, "%s", d.Text)
+	return h + 3
+}
+
+func (d *ConfirmDialog) render(r Rect) {
+	fg, bg := termbox.ColorDefault, termbox.ColorDefault
+
+	writeText(r.X, r.Y, r.X+r.W-1, "%s", fg, bg, d.Text)
+	writeText(r.X, r.Y+r.H-2, -1, "^wENTER^! to %s", fg, bg, d.Yes)
+	writeText(r.X, r.Y+r.H-1, -1, "^wESC^! to %s", fg, bg, d.No)
+}
+
+func (d *ConfirmDialog) handleKey(ch rune, key termbox.Key) bool {
+	switch {
+	case key == termbox.KeyEnter || ch == 'y' || ch == 'Y':
+		d.OnResult(true)
+	case key == termbox.KeyEsc || ch == 'n' || ch == 'N':
+		d.OnResult(false)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// MenuDialog
+
+func (d *MenuDialog) title() string { return d.Title }
+
+func (d *MenuDialog) height(width int) int {
+	return len(d.Options) + 2
+}
+
+func (d *MenuDialog) render(r Rect) {
+	var (
+		fg, bg = termbox.ColorDefault, termbox.ColorDefault
+		rows   = r.H - 2
+		first  = 0
+	)
+
+	// Long menus scroll to keep the selected option in sight.
+	if d.Selected >= rows {
+		first = d.Selected - rows + 1
+	}
+
+	for i := first; i < len(d.Options) && i-first < rows; i++ {
+		if i == d.Selected {
+			writeText(r.X, r.Y+i-first, -1, "^B> %s^!", fg, bg, d.Options[i])
+		} else {
+			writeText(r.X, r.Y+i-first, -1, "  %s", fg, bg, d.Options[i])
+		}
+	}
+
+	writeText(r.X, r.Y+r.H-1, -1, "^w▲▼^! to choose, ^wENTER^! to select, ^wESC^! to cancel", CurrentTheme.Fg("dim"), bg)
+}
+
+func (d *MenuDialog) handleKey(ch rune, key termbox.Key) bool {
+	switch key {
+	case termbox.KeyArrowUp:
+		if len(d.Options) > 0 {
+			d.Selected = (d.Selected + len(d.Options) - 1) % len(d.Options)
+		}
+	case termbox.KeyArrowDown:
+		if len(d.Options) > 0 {
+			d.Selected = (d.Selected + 1) % len(d.Options)
+		}
+	case termbox.KeyEnter:
+		if len(d.Options) == 0 {
+			return false
+		}
+
+		d.OnSelect(d.Selected)
+		return true
+	case termbox.KeyEsc:
+		d.OnSelect(-1)
+		return true
+	}
+
+	return false
+}
+
+// InputDialog
+
+func (d *InputDialog) title() string { return d.Title }
+
+func (d *InputDialog) height(width int) int {
+	_, h := measureText(width, "%s", d.Prompt)
+	return h + 3
+}
+
+func (d *InputDialog) render(r Rect) {
+	fg, bg := termbox.ColorDefault, termbox.ColorDefault
+
+	writeText(r.X, r.Y, r.X+r.W-1, "%s", fg, bg, d.Prompt)
+
+	// Only the end of the text is shown if it's too long to fit.
+	text := []rune(d.Text)
+	if len(text) > r.W-3 {
+		text = text[len(text)-(r.W-3):]
+	}
+
+	writeText(r.X, r.Y+r.H-2, -1, "> %s", fg, bg, strings.Replace(string(text), "^", "^^", -1))
+	highlight(r.X+2+len(text), r.Y+r.H-2)
+
+	writeText(r.X, r.Y+r.H-1, -1, "^wENTER^! to accept, ^wESC^! to cancel", CurrentTheme.Fg("dim"), bg)
+}
+
+func (d *InputDialog) handleKey(ch rune, key termbox.Key) bool {
+	text := []rune(d.Text)
+
+	switch key {
+	case termbox.KeyEnter:
+		d.OnSubmit(d.Text, true)
+		return true
+	case termbox.KeyEsc:
+		d.OnSubmit("", false)
+		return true
+	case termbox.KeyBackspace, termbox.KeyBackspace2:
+		if len(text) > 0 {
+			d.Text = string(text[:len(text)-1])
+		}
+	case termbox.KeySpace:
+		ch = ' '
+	}
+
+	if ch != 0 && (d.MaxLength <= 0 || len(text) < d.MaxLength) {
+		d.Text += string(ch)
+	}
+
+	return false
+}
+
+// PagedDialog
+
+func (d *PagedDialog) title() string { return d.Title }
+
+func (d *PagedDialog) height(width int) int {
+	_, h := measureText(width, "%s", d.Text)
+	return h + 2
+}
+
+func (d *PagedDialog) render(r Rect) {
+	var (
+		fg, bg      = termbox.ColorDefault, termbox.ColorDefault
+		cells, _, h = layoutText(parseMarkup(d.Text, fg, bg), r.W)
+	)
+
+	d.pageHeight = maxInt(1, r.H-2)
+	pages := (h + d.pageHeight - 1) / d.pageHeight
+	d.Page = clamp(d.Page, 0, maxInt(0, pages-1))
+
+	for _, c := range cells {
+		if y := c.Y - d.Page*d.pageHeight; y >= 0 && y < d.pageHeight {
+			setCell(r.X+c.X, r.Y+y, c.Ch, c.Style.Fg, c.Style.Bg)
+		}
+	}
+
+	if pages > 1 {
+		writeText(r.X, r.Y+r.H-1, -1, "page %d/%d: ^w◀▶^! to turn the page, ^wESC^! to close", CurrentTheme.Fg("dim"), bg, d.Page+1, pages)
+	} else {
+		writeText(r.X, r.Y+r.H-1, -1, "^wESC^! to close", CurrentTheme.Fg("dim"), bg)
+	}
+}
+
+func (d *PagedDialog) handleKey(ch rune, key termbox.Key) bool {
+	switch key {
+	case termbox.KeyArrowLeft, termbox.KeyArrowUp:
+		d.Page--
+	case termbox.KeyArrowRight, termbox.KeyArrowDown, termbox.KeySpace:
+		d.Page++
+	case termbox.KeyEsc, termbox.KeyEnter:
+		if d.OnClose != nil {
+			d.OnClose()
+		}
+
+		return true
+	}
+
+	// Turning the page is clamped to the pages there are when it's drawn.
+	d.Page = maxInt(0, d.Page)
+
+	return false
+}
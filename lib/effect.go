@@ -0,0 +1,260 @@
+package lib
+
+import (
+	"fmt"
+
+	"github.com/nsf/termbox-go"
+)
+
+// The set of status effect kinds
+const (
+	_ int = iota
+
+	EffectPoison
+	EffectRegeneration
+	EffectHaste
+	EffectSlow
+	EffectBlindness
+	EffectConfusion
+)
+
+// The set of stacking rules, which say what happens when an effect is
+// applied to a creature which already has an effect of the same kind.
+const (
+	_ int = iota
+
+	// StackRefresh keeps the longer of the two durations
+	StackRefresh
+
+	// StackExtend adds the new duration onto the old one
+	StackExtend
+
+	// StackIntensify adds the potencies together and keeps the longer
+	// duration
+	StackIntensify
+)
+
+type (
+	// A Creature is something which can be affected by status effects, such
+	// as the player.
+	Creature interface {
+		Name() string
+		Damage(amount int, cause string)
+		Heal(amount int)
+		Status() *Effects
+	}
+
+	// An Effect is a single status effect on a creature, which lasts for a
+	// certain number of turns.
+	Effect struct {
+		Kind    int
+		Turns   int
+		Potency int
+	}
+
+	// Effects is the set of status effects which are active on a creature.
+	Effects struct {
+		Active []*Effect
+	}
+
+	// effectInfo stores the properties shared by all effects of a kind.
+	effectInfo struct {
+		Name     string
+		Colour   termbox.Attribute
+		Stacking int
+
+		// Opposite is the kind of effect which this one cancels out when
+		// it's applied, e.g. haste and slow.
+		Opposite int
+
+		// OnTick is called once every turn while the effect is active.
+		OnTick func(c Creature, e *Effect)
+
+		// Stats are added to the player's effective stats while the effect
+		// is active.
+		Stats Stats
+	}
+)
+
+var effectInfos = map[int]effectInfo{
+	EffectPoison: {
+		Name:     "poison",
+		Colour:   termbox.ColorGreen,
+		Stacking: StackIntensify,
+		OnTick: func(c Creature, e *Effect) {
+			c.Damage(e.Potency, "poison")
+		},
+	},
+
+	EffectRegeneration: {
+		Name:     "regen",
+		Colour:   termbox.ColorRed,
+		Stacking: StackRefresh,
+		OnTick: func(c Creature, e *Effect) {
+			c.Heal(e.Potency)
+		},
+	},
+
+	EffectHaste: {
+		Name:     "haste",
+		Colour:   termbox.ColorCyan,
+		Stacking: StackExtend,
+		Opposite: EffectSlow,
+		Stats:    Stats{Speed: 4},
+	},
+
+	EffectSlow: {
+		Name:     "slow",
+		Colour:   termbox.ColorBlue,
+		Stacking: StackExtend,
+		Opposite: EffectHaste,
+		Stats:    Stats{Speed: -4},
+	},
+
+	EffectBlindness: {
+		Name:     "blind",
+		Colour:   termbox.ColorWhite,
+		Stacking: StackRefresh,
+		Stats:    Stats{Attack: -2},
+	},
+
+	EffectConfusion: {
+		Name:     "confused",
+		Colour:   termbox.ColorMagenta,
+		Stacking: StackExtend,
+		Stats:    Stats{Defense: -2},
+	},
+}
+
+// Add applies a new effect, lasting for the given number of turns. If an
+// effect of the same kind is already active, they're combined according to
+// the kind's stacking rule.
+func (e *Effects) Add(kind, turns, potency int) {
+	info := effectInfos[kind]
+
+	if info.Opposite != 0 && e.Has(info.Opposite) {
+		e.Remove(info.Opposite)
+		return
+	}
+
+	existing := e.Get(kind)
+	if existing == nil {
+		e.Active = append(e.Active, &Effect{
+			Kind:    kind,
+			Turns:   turns,
+			Potency: potency,
+		})
+
+		return
+	}
+
+	switch info.Stacking {
+	case StackRefresh:
+		existing.Turns = maxInt(existing.Turns, turns)
+		existing.Potency = maxInt(existing.Potency, potency)
+
+	case StackExtend:
+		existing.Turns += turns
+		existing.Potency = maxInt(existing.Potency, potency)
+
+	case StackIntensify:
+		existing.Turns = maxInt(existing.Turns, turns)
+		existing.Potency += potency
+	}
+}
+
+// Get returns the active effect of the given kind, or nil if there isn't
+// one.
+func (e *Effects) Get(kind int) *Effect {
+	for _, eff := range e.Active {
+		if eff.Kind == kind {
+			return eff
+		}
+	}
+
+	return nil
+}
+
+// Has checks whether an effect of the given kind is active.
+func (e *Effects) Has(kind int) bool {
+	return e.Get(kind) != nil
+}
+
+// Remove removes any active effect of the given kind.
+func (e *Effects) Remove(kind int) {
+	kept := e.Active[:0]
+
+	for _, eff := range e.Active {
+		if eff.Kind != kind {
+			kept = append(kept, eff)
+		}
+	}
+
+	e.Active = kept
+}
+
+// Tick processes a single turn for every active effect on a creature,
+// removing the ones which have run out.
+func (e *Effects) Tick(c Creature) {
+	for _, eff := range e.Active {
+		if tick := effectInfos[eff.Kind].OnTick; tick != nil {
+			tick(c, eff)
+		}
+
+		eff.Turns--
+	}
+
+	kept := e.Active[:0]
+
+	for _, eff := range e.Active {
+		if eff.Turns > 0 {
+			kept = append(kept, eff)
+		}
+	}
+
+	e.Active = kept
+}
+
+// effectByName finds the kind of effect with the given name.
+func effectByName(name string) (int, bool) {
+	for kind, info := range effectInfos {
+		if info.Name == name {
+			return kind, true
+		}
+	}
+
+	return 0, false
+}
+
+// Name returns the human-readable name of an effect.
+func (e *Effect) Name() string {
+	return effectInfos[e.Kind].Name
+}
+
+// Render renders the active effects in a row, starting at (x, y), and
+// returns the x coordinate after the last one.
+func (e *Effects) Render(x, y int) int {
+	for _, eff := range e.Active {
+		text := fmt.Sprintf("%s(%d) ", eff.Name(), eff.Turns)
+		writeText(x, y, -1, text, effectInfos[eff.Kind].Colour, termbox.ColorDefault)
+		x += len(text)
+	}
+
+	return x
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,112 @@
+package lib
+
+// Explored checks whether the player has seen the tile at (x, y). Without
+// fog of war, every tile counts as explored.
+func (m *Map) Explored(x, y int) bool {
+	if !Conf.FogOfWar {
+		return true
+	}
+
+	return m.Seen != nil && m.Seen[y][x]
+}
+
+// Explore marks every tile the player can see from (cx, cy) as explored.
+// The walls around any floor they can see are explored too, since lines of
+// sight which just graze a wall tend to be blocked by the wall next to it.
+func (m *Map) Explore(cx, cy, radius int) {
+	if m.Seen == nil {
+		m.Seen = make([][]bool, m.Height())
+		for i := range m.Seen {
+			m.Seen[i] = make([]bool, m.Width())
+		}
+	}
+
+	for y := cy - radius; y <= cy+radius; y++ {
+		for x := cx - radius; x <= cx+radius; x++ {
+			if !m.CanSee(cx, cy, x, y, radius) {
+				continue
+			}
+
+			m.Seen[y][x] = true
+
+			if opaque(m.At(x, y)) {
+				continue
+			}
+
+			for ny := y - 1; ny <= y+1; ny++ {
+				for nx := x - 1; nx <= x+1; nx++ {
+					if nx >= 0 && ny >= 0 && nx < m.Width() && ny < m.Height() && opaque(m.At(nx, ny)) {
+						m.Seen[ny][nx] = true
+					}
+				}
+			}
+		}
+	}
+}
+
+// CanSee checks whether (x2, y2) can be seen from (x1, y1): it has to be
+// on the map, within radius tiles, and there mustn't be any walls in the
+// way.
+func (m *Map) CanSee(x1, y1, x2, y2, radius int) bool {
+	if x2 < 0 || y2 < 0 || x2 >= m.Width() || y2 >= m.Height() {
+		return false
+	}
+
+	dx, dy := x2-x1, y2-y1
+	if dx*dx+dy*dy > radius*radius {
+		return false
+	}
+
+	return m.lineOfSight(x1, y1, x2, y2)
+}
+
+// lineOfSight walks along a line from (x1, y1) to (x2, y2), checking that
+// none of the tiles between them block the view. The tiles at either end
+// don't count, so walls can be seen but not seen through.
+func (m *Map) lineOfSight(x1, y1, x2, y2 int) bool {
+	var (
+		dx, dy = abs(x2 - x1), -abs(y2 - y1)
+		sx, sy = sign(x2 - x1), sign(y2 - y1)
+		err    = dx + dy
+		x, y   = x1, y1
+	)
+
+	for x != x2 || y != y2 {
+		if (x != x1 || y != y1) && opaque(m.At(x, y)) {
+			return false
+		}
+
+		e2 := 2 * err
+
+		if e2 >= dy {
+			err += dy
+			x += sx
+		}
+
+		if e2 <= dx {
+			err += dx
+			y += sy
+		}
+	}
+
+	return true
+}
+
+// opaque checks whether a tile blocks the view.
+func opaque(t Tile) bool {
+	return t.Type() == TileWall || t.Type() == TileOutside
+}
+
+// viewRadius returns how far the player can see.
+func (p *Player) viewRadius() int {
+	if p.Effects.Has(EffectBlindness) {
+		return 1
+	}
+
+	return Conf.ViewRadius
+}
+
+// look explores everything the player can see from where they are.
+func (p *Player) look() {
+	p.Game.Level.Explore(p.X, p.Y, p.viewRadius())
+}
@@ -1,6 +1,9 @@
 package lib
 
-import "time"
+import (
+	"image"
+	"time"
+)
 
 // The Game stores the game state so it can be easily passed around.
 type Game struct {
@@ -8,11 +11,112 @@ type Game struct {
 	Player   *Player
 	UI       *UI
 	LastMove time.Time
+
+	// Turn is the number of turns which have passed in the world.
+	Turn int
+
+	// Seed is the seed the random number generator was given at the start
+	// of the run.
+	Seed int64
+
+	// Known is what the player has found out about the items in the
+	// catalogue so far in the run. Every run has its own, so that the
+	// appearances are shuffled again.
+	Known *Knowledge
+
+	// Dialogs are the dialogs open on top of the game, with the one taking
+	// the player's input last.
+	Dialogs []Dialog
+
+	// Over is set once the player has died and closed the death screen,
+	// which ends the run.
+	Over bool
+
+	// Narrator describes the game in words, when narration is turned on.
+	Narrator *Narrator
+
+	// Animations are the animations waiting to be played, with the one
+	// which is playing first.
+	Animations []*Animation
+
+	// timeOwed is how much time the player's actions have taken which the
+	// world hasn't caught up with yet. Fast players' actions take less than
+	// a turn each, so the world doesn't move after every one.
+	timeOwed float64
 }
 
 // Render renders the game to termbox
 func (g *Game) Render() {
-	g.Level.Render(2, 1)
-	g.Player.Render(2, 1)
-	g.UI.Render(100, 1)
+	var (
+		l      = g.Layout()
+		ox, oy = l.mapOrigin()
+	)
+
+	if g.Player.Effects.Has(EffectBlindness) {
+		g.Level.RenderAround(ox, oy, l.View, g.Player.X, g.Player.Y, 1)
+	} else {
+		g.Level.Render(ox, oy, l.View)
+	}
+
+	for _, a := range g.Level.Actors {
+		if !image.Pt(a.X, a.Y).In(l.View) {
+			continue
+		}
+
+		if g.Player.Effects.Has(EffectBlindness) && (abs(a.X-g.Player.X) > 1 || abs(a.Y-g.Player.Y) > 1) {
+			continue
+		}
+
+		if Conf.FogOfWar && !g.Level.CanSee(g.Player.X, g.Player.Y, a.X, a.Y, g.Player.viewRadius()) {
+			continue
+		}
+
+		a.Render(ox, oy)
+	}
+
+	g.Player.Render(ox, oy)
+	g.renderAnimation(ox, oy, l.View)
+	g.UI.Render(l)
+	g.renderDialog()
+}
+
+// EndTurn is called whenever the player does something which takes up
+// time. How long each action takes depends on the player's speed, so a fast
+// player might get several actions a turn and a slow player's actions might
+// each take more than one.
+func (g *Game) EndTurn() {
+	g.timeOwed += actionCost(g.Player.Effective().Speed)
+
+	for g.timeOwed >= 1 {
+		g.timeOwed--
+		g.Tick()
+	}
+
+	g.narrateSurroundings()
+}
+
+// Tick advances the world by a single turn.
+func (g *Game) Tick() {
+	g.Turn++
+	g.Player.Effects.Tick(g.Player)
+	g.tickHunger()
+	g.tickItems()
+
+	for _, a := range g.Level.Actors {
+		acts := 1
+
+		if a.Effects.Has(EffectHaste) {
+			acts = 2
+		} else if a.Effects.Has(EffectSlow) && g.Turn%2 == 0 {
+			acts = 0
+		}
+
+		for i := 0; i < acts; i++ {
+			a.Act(g)
+		}
+
+		a.Effects.Tick(a)
+	}
+
+	g.reap()
 }
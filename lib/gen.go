@@ -5,7 +5,6 @@ import (
 	"image/color"
 	"math"
 	"math/rand"
-	"time"
 
 	"github.com/llgcode/draw2d"
 	"github.com/llgcode/draw2d/draw2dimg"
@@ -20,11 +19,6 @@ var (
 	jobResults chan jobResult
 )
 
-func init() {
-	jobs = make(chan job, Conf.NumThreads)
-	jobResults = make(chan jobResult, Conf.NumThreads)
-}
-
 // DecodeImageIntoMap takes an image.Image object and converts its
 // pixels into the tiles in a Map.
 func DecodeImageIntoMap(img image.Image) *Map {
@@ -91,6 +85,9 @@ type jobResult struct {
 
 // SpawnWorkers spawns a bunch of worker goroutines to speed up Prim's algorithm
 func SpawnWorkers() {
+	jobs = make(chan job, Conf.NumThreads)
+	jobResults = make(chan jobResult, Conf.NumThreads)
+
 	for i := 0; i < Conf.NumThreads; i++ {
 		go worker()
 	}
@@ -186,9 +183,12 @@ func initiateJobs(graph [][]int, labelled, deleted []int) {
 	}
 }
 
-// MakeMap generates a new map
+// MakeMap generates a new map. Every few levels, this will be a boss
+// level instead of a normal one.
 func MakeMap(depth int) *Map {
-	rand.Seed(time.Now().UnixNano())
+	if isBossLevel(depth) {
+		return MakeBossMap(depth)
+	}
 
 	var (
 		img = image.NewRGBA(image.Rect(0, 0, Conf.MapWidth, Conf.MapHeight))
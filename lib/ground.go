@@ -0,0 +1,138 @@
+package lib
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ItemsAt returns the items lying on the floor at (x, y).
+func (m *Map) ItemsAt(x, y int) []Item {
+	if items := floorItems(m.At(x, y)); items != nil {
+		return *items
+	}
+
+	return nil
+}
+
+// floorItems returns the items lying on a tile, or nil if it isn't one
+// which items can be put on.
+func floorItems(t Tile) *[]Item {
+	switch t := t.(type) {
+	case *FloorTile:
+		return &t.Items
+	case *TrapTile:
+		return &t.Items
+	}
+
+	return nil
+}
+
+// Spill puts some items on the floor at (x, y). If that isn't a floor
+// tile, they go on the nearest one instead.
+func (m *Map) Spill(x, y int, items []Item) {
+	if len(items) == 0 {
+		return
+	}
+
+	fx, fy, ok := m.nearestFloor(x, y)
+	if !ok {
+		return
+	}
+
+	pile := floorItems(m.At(fx, fy))
+	*pile = append(*pile, items...)
+}
+
+// nearestFloor finds the closest floor tile to (x, y), searching outwards
+// in rings.
+func (m *Map) nearestFloor(x, y int) (int, int, bool) {
+	for r := 0; r < m.Width() || r < m.Height(); r++ {
+		for ny := y - r; ny <= y+r; ny++ {
+			for nx := x - r; nx <= x+r; nx++ {
+				if abs(nx-x) != r && abs(ny-y) != r {
+					continue
+				}
+
+				if nx < 0 || ny < 0 || nx >= m.Width() || ny >= m.Height() {
+					continue
+				}
+
+				if walkableFloor(m.At(nx, ny)) {
+					return nx, ny, true
+				}
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+// withArticle puts "a" or "an" in front of a noun, going by its first
+// letter.
+func withArticle(noun string) string {
+	if noun != "" && strings.ContainsRune("aeiouAEIOU", rune(noun[0])) {
+		return "an " + noun
+	}
+
+	return "a " + noun
+}
+
+// describeItems makes a list of items for a message, e.g. "a sword, a
+// healing potion and an amulet".
+func describeItems(items []Item) string {
+	names := make([]string, len(items))
+
+	for i, item := range items {
+		names[i] = withArticle(item.Name())
+	}
+
+	if len(names) == 1 {
+		return names[0]
+	}
+
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
+
+// PickUp picks up every item lying on the floor under the player, or as
+// many as they have room for. It takes up a turn if anything's picked up.
+func (p *Player) PickUp() {
+	pile := floorItems(p.Game.Level.At(p.X, p.Y))
+	if pile == nil || len(*pile) == 0 {
+		p.Game.UI.SetMinibuf("There's nothing here to pick up.", time.Second*4)
+		return
+	}
+
+	var taken, left []Item
+
+	for _, item := range *pile {
+		if p.AddItem(item) {
+			taken = append(taken, item)
+		} else {
+			left = append(left, item)
+		}
+	}
+
+	*pile = left
+
+	if len(taken) == 0 {
+		p.Game.UI.SetMinibuf("You can't carry any more.", time.Second*4)
+		return
+	}
+
+	msg := fmt.Sprintf("You pick up %s.", describeItems(taken))
+	if len(left) > 0 {
+		msg += " You can't carry the rest."
+	}
+
+	p.Game.UI.SetMinibuf(msg, time.Second*4)
+	p.Game.EndTurn()
+}
+
+// seeItems tells the player what's lying on the floor where they're
+// standing.
+func (p *Player) seeItems() {
+	if items := p.Game.Level.ItemsAt(p.X, p.Y); len(items) > 0 {
+		p.Game.UI.SetMinibuf(fmt.Sprintf("You see %s here. Press G to pick up.", describeItems(items)), time.Second*4)
+	}
+}
@@ -0,0 +1,105 @@
+package lib
+
+import (
+	"time"
+
+	"github.com/nsf/termbox-go"
+)
+
+// A HungerStage is how hungry the player is. Each stage is worse than the
+// one before it.
+type HungerStage int
+
+// The hunger stages
+const (
+	HungerFed HungerStage = iota
+	HungerHungry
+	HungerWeak
+	HungerStarving
+)
+
+// hungerInfo says how a hunger stage is shown, and the warning given when
+// the player reaches it.
+type hungerInfo struct {
+	Name    string
+	Colour  termbox.Attribute
+	Warning string
+}
+
+var hungerInfos = map[HungerStage]hungerInfo{
+	HungerFed: {
+		Name:   "",
+		Colour: termbox.ColorDefault,
+	},
+	HungerHungry: {
+		Name:    "hungry",
+		Colour:  termbox.ColorYellow,
+		Warning: "Your stomach rumbles. You're getting hungry.",
+	},
+	HungerWeak: {
+		Name:    "weak",
+		Colour:  termbox.ColorRed,
+		Warning: "You feel weak with hunger. Your blows are losing their strength.",
+	},
+	HungerStarving: {
+		Name:    "starving",
+		Colour:  termbox.ColorRed | termbox.AttrBold,
+		Warning: "You're starving! Eat something, quickly!",
+	},
+}
+
+// Hunger returns the player's current hunger stage.
+func (p *Player) Hunger() HungerStage {
+	switch {
+	case p.Satiety <= 0:
+		return HungerStarving
+	case p.Satiety <= Conf.WeakAt:
+		return HungerWeak
+	case p.Satiety <= Conf.HungryAt:
+		return HungerHungry
+	default:
+		return HungerFed
+	}
+}
+
+// Eat fills the player's stomach by the given amount, up to the most they
+// can eat.
+func (p *Player) Eat(amount int) {
+	p.Satiety += amount
+
+	if p.Satiety > Conf.MaxSatiety {
+		p.Satiety = Conf.MaxSatiety
+	}
+}
+
+// tickHunger makes the player a turn hungrier, warning them when they get
+// to a worse stage. A starving player loses health every turn.
+func (g *Game) tickHunger() {
+	p := g.Player
+	before := p.Hunger()
+
+	if p.Satiety > 0 {
+		p.Satiety--
+	}
+
+	if stage := p.Hunger(); stage > before {
+		g.UI.SetMinibuf(hungerInfos[stage].Warning, time.Second*4)
+	}
+
+	if p.Hunger() == HungerStarving {
+		p.Damage(Conf.StarveDamage, "starvation")
+	}
+}
+
+// Render draws the hunger stage's name at (x, y), returning the x
+// coordinate after it. Nothing is drawn if the player isn't hungry.
+func (h HungerStage) Render(x, y int) int {
+	info := hungerInfos[h]
+	if info.Name == "" {
+		return x
+	}
+
+	writeText(x, y, -1, "%s ", info.Colour, termbox.ColorDefault, info.Name)
+
+	return x + len(info.Name) + 1
+}
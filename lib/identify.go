@@ -0,0 +1,178 @@
+package lib
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// Knowledge stores which item types and modifiers the player has
+// identified, and what the unidentified ones look like in this run. If it's
+// nil, everything is known.
+type Knowledge struct {
+	// TypeAppearances and ModifierAppearances are what each unidentified
+	// type and hidden modifier is called, e.g. "murky" or "runed".
+	TypeAppearances     map[string]string
+	ModifierAppearances map[string]string
+
+	// Types and Modifiers are the names of the types and modifiers which
+	// have been identified.
+	Types     map[string]bool
+	Modifiers map[string]bool
+}
+
+// NewKnowledge makes a new set of knowledge where nothing is identified,
+// giving every unidentified type and hidden modifier in the catalogue a
+// random appearance.
+func NewKnowledge(c *Catalogue) *Knowledge {
+	k := &Knowledge{
+		TypeAppearances:     make(map[string]string),
+		ModifierAppearances: make(map[string]string),
+		Types:               make(map[string]bool),
+		Modifiers:           make(map[string]bool),
+	}
+
+	perms := make(map[string][]int)
+
+	for _, name := range c.typeNames {
+		kind := c.Types[name].Kind
+		if len(kind.Appearances) == 0 {
+			continue
+		}
+
+		if _, ok := perms[kind.Name]; !ok {
+			perms[kind.Name] = rand.Perm(len(kind.Appearances))
+		}
+
+		k.TypeAppearances[name] = kind.Appearances[perms[kind.Name][0]]
+		perms[kind.Name] = perms[kind.Name][1:]
+	}
+
+	perm := rand.Perm(len(c.ModifierAppearances))
+
+	for _, name := range c.modifierNames {
+		if c.Modifiers[name].Hidden {
+			k.ModifierAppearances[name] = c.ModifierAppearances[perm[0]]
+			perm = perm[1:]
+		}
+	}
+
+	return k
+}
+
+// typeName returns what the player knows an item type as.
+func (k *Knowledge) typeName(t ItemType) string {
+	if k == nil || k.Types[t.Name] || len(t.Kind.Appearances) == 0 {
+		return t.Name
+	}
+
+	return fmt.Sprintf(t.Kind.Unidentified, k.TypeAppearances[t.Name])
+}
+
+// modifierName returns what the player knows a modifier as.
+func (k *Knowledge) modifierName(m ItemModifier) string {
+	if k == nil || k.Modifiers[m.Name] || !m.Hidden {
+		return m.Name
+	}
+
+	return k.ModifierAppearances[m.Name]
+}
+
+// Identified checks whether the player knows everything about an item.
+func (k *Knowledge) Identified(i Item) bool {
+	if k == nil {
+		return true
+	}
+
+	if len(i.Type().Kind.Appearances) > 0 && !k.Types[i.Type().Name] {
+		return false
+	}
+
+	for _, mod := range i.Modifiers() {
+		if mod.Hidden && !k.Modifiers[mod.Name] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Identify identifies an item's type and all of its modifiers, so every
+// other item like it is identified too. It returns false if the item was
+// already identified.
+func (k *Knowledge) Identify(i Item) bool {
+	if k == nil || k.Identified(i) {
+		return false
+	}
+
+	k.Types[i.Type().Name] = true
+
+	for _, mod := range i.Modifiers() {
+		k.Modifiers[mod.Name] = true
+	}
+
+	return true
+}
+
+// IdentifyAll identifies every item type and modifier in the catalogue.
+func (k *Knowledge) IdentifyAll(c *Catalogue) {
+	if k == nil {
+		return
+	}
+
+	for name := range c.Types {
+		k.Types[name] = true
+	}
+
+	for name := range c.Modifiers {
+		k.Modifiers[name] = true
+	}
+}
+
+// identifyRandom identifies one of the player's unidentified items at
+// random, apart from the given one (which is the scroll being read). It
+// returns a message saying what happened.
+func (p *Player) identifyRandom(except Item) string {
+	var unknown []Item
+
+	for _, item := range p.Inventory {
+		if item != except && !p.Game.Known.Identified(item) {
+			unknown = append(unknown, item)
+		}
+	}
+
+	if len(unknown) == 0 {
+		return "You have nothing to identify."
+	}
+
+	item := unknown[rand.Intn(len(unknown))]
+	before := item.Name()
+	p.Game.Known.Identify(item)
+
+	return fmt.Sprintf("The %s is %s!", before, withArticle(item.Name()))
+}
+
+// InspectItem has the player study one of their items, to try to work out
+// what it is. The better their magic, the more likely they are to manage
+// it. Each item can only be inspected once, and it takes up a turn.
+func (p *Player) InspectItem(item Item) string {
+	if p.Game.Known.Identified(item) {
+		return "You already know what the " + item.Name() + " is."
+	}
+
+	b, ok := item.(*BasicItem)
+	if !ok || b.Inspected {
+		return "You can't learn any more by looking at the " + item.Name() + "."
+	}
+
+	b.Inspected = true
+	defer p.Game.EndTurn()
+
+	if rand.Float64() >= Conf.InspectChance+float64(p.Effective().Magic)*0.05 {
+		return "You study the " + item.Name() + ", but can't make it out."
+	}
+
+	before := item.Name()
+	p.Game.Known.Identify(item)
+
+	return fmt.Sprintf("You study the %s. It's %s!", before, withArticle(item.Name()))
+}
@@ -0,0 +1,306 @@
+package lib
+
+import (
+	"fmt"
+
+	"github.com/nsf/termbox-go"
+)
+
+// The set of equipment slots, in the order they're shown in.
+var slots = []string{"weapon", "armour", "ring", "amulet"}
+
+// validSlot checks whether the given name is an equipment slot.
+func validSlot(name string) bool {
+	for _, s := range slots {
+		if s == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkStartingItems makes sure every item in the config's starting-items
+// list exists, and that they all fit in the player's inventory.
+func checkStartingItems() error {
+	if len(Conf.StartingItems) > Conf.InventorySize {
+		return fmt.Errorf("%d starting items, but only room for %d", len(Conf.StartingItems), Conf.InventorySize)
+	}
+
+	for _, name := range Conf.StartingItems {
+		if _, ok := Items.Types[name]; !ok {
+			return fmt.Errorf("unknown starting item %q", name)
+		}
+	}
+
+	return nil
+}
+
+// giveStartingItems puts the items from the config's starting-items list
+// into the player's inventory, and equips any that can be. The player knows
+// what their starting items are. The list has already been checked by
+// checkStartingItems.
+func (p *Player) giveStartingItems() {
+	for _, name := range Conf.StartingItems {
+		item, err := Items.NewItem(p.Game.Known, name, Items.Rarities[0].Name)
+		if err != nil || !p.AddItem(item) {
+			continue
+		}
+
+		p.Game.Known.Identify(item)
+
+		if slot := item.Type().Kind.Slot; slot != "" && p.Equipment[slot] == nil {
+			p.Equip(item)
+		}
+	}
+}
+
+// AddItem puts an item into the player's inventory, returning false if
+// there's no room for it.
+func (p *Player) AddItem(item Item) bool {
+	if len(p.Inventory) >= Conf.InventorySize {
+		return false
+	}
+
+	p.Inventory = append(p.Inventory, item)
+	return true
+}
+
+// RemoveItem takes an item out of the player's inventory, unequipping it
+// first if it's equipped. It returns false if the item is equipped and
+// can't be taken off.
+func (p *Player) RemoveItem(item Item) bool {
+	if p.Equipped(item) && !p.Unequip(item.Type().Kind.Slot) {
+		return false
+	}
+
+	for i, it := range p.Inventory {
+		if it == item {
+			p.Inventory = append(p.Inventory[:i], p.Inventory[i+1:]...)
+			break
+		}
+	}
+
+	return true
+}
+
+// Equipped checks whether an item is equipped.
+func (p *Player) Equipped(item Item) bool {
+	slot := item.Type().Kind.Slot
+	return slot != "" && p.Equipment[slot] == item
+}
+
+// Equip equips an item in its slot, replacing whatever was in there before,
+// and identifies it. It returns false if the item can't be equipped, or if
+// the item already in the slot can't be taken off.
+func (p *Player) Equip(item Item) bool {
+	slot := item.Type().Kind.Slot
+	if slot == "" || !p.Unequip(slot) {
+		return false
+	}
+
+	p.Equipment[slot] = item
+	p.Game.Known.Identify(item)
+
+	return true
+}
+
+// Unequip takes off whatever is equipped in a slot. It returns false if
+// the item in the slot is cursed, and so can't be taken off.
+func (p *Player) Unequip(slot string) bool {
+	if item := p.Equipment[slot]; item != nil && cursed(item) {
+		return false
+	}
+
+	delete(p.Equipment, slot)
+
+	if max := p.Effective().Health; p.Health > max {
+		p.Health = max
+	}
+
+	return true
+}
+
+// Drop drops an item onto the floor where the player is standing,
+// returning a message saying what happened.
+func (p *Player) Drop(item Item) string {
+	if !p.RemoveItem(item) {
+		return "You can't let go of the " + item.Name() + ". It's cursed!"
+	}
+
+	p.Game.Level.Spill(p.X, p.Y, []Item{item})
+
+	return "You drop the " + item.Name() + "."
+}
+
+// UseItem uses a consumable item, applying its effects and using it up,
+// and returns a message saying what happened.
+func (p *Player) UseItem(item Item) string {
+	use := item.Type().Use
+	if use == nil {
+		return "You can't use that."
+	}
+
+	if use.Heal > 0 {
+		p.Heal(use.Heal)
+	}
+
+	if use.Food > 0 {
+		p.Eat(use.Food)
+	}
+
+	if kind, ok := effectByName(use.Effect); ok {
+		p.Effects.Add(kind, use.Turns, use.Potency)
+	}
+
+	if kind, ok := effectByName(use.Cure); ok {
+		p.Effects.Remove(kind)
+	}
+
+	verb := "use"
+	switch item.Type().Kind.Name {
+	case "food":
+		verb = "eat"
+	case "potion":
+		verb = "drink"
+	case "scroll":
+		verb = "read"
+	}
+
+	p.Game.Known.Identify(item)
+
+	e := &UseEvent{
+		Item:    item,
+		Game:    p.Game,
+		Message: fmt.Sprintf("You %s the %s.", verb, item.Name()),
+	}
+
+	if use.Identify {
+		e.say("%s", p.identifyRandom(item))
+	}
+
+	useItem(e)
+
+	p.RemoveItem(item)
+	p.Game.EndTurn()
+
+	return e.Message
+}
+
+// ShowInventory shows the inventory screen, where the player can equip,
+// unequip, use and drop their items. It blocks until they close it.
+func (p *Player) ShowInventory() {
+	var (
+		cursor = 0
+		fg, bg = termbox.ColorDefault, termbox.ColorDefault
+		status = ""
+	)
+
+	for {
+		if cursor >= len(p.Inventory) {
+			cursor = len(p.Inventory) - 1
+		}
+
+		if cursor < 0 {
+			cursor = 0
+		}
+
+		termbox.Clear(fg, bg)
+
+		writeText(1, 1, -1, "^BInventory^! (%d/%d)", fg, bg, len(p.Inventory), Conf.InventorySize)
+
+		if len(p.Inventory) == 0 {
+			writeText(3, 3, -1, "You aren't carrying anything.", CurrentTheme.Fg("dim"), bg)
+		}
+
+		for i, item := range p.Inventory {
+			marker := " "
+			if i == cursor {
+				marker = ">"
+			}
+
+			writeText(1, 3+i, -1, marker, fg|termbox.AttrBold, bg)
+
+			if p.Equipped(item) {
+				writeText(3, 3+i, -1, "E", termbox.ColorCyan, bg)
+			}
+
+			writeText(5, 3+i, -1, "%s %s", item.Rarity().Fg, item.Rarity().Bg, item.Type().Glyph, item.Name())
+		}
+
+		x := 50
+		writeText(x, 1, -1, "^BEquipment^!", fg, bg)
+
+		for i, slot := range slots {
+			writeText(x, 3+i, -1, "%7s:", fg, bg, slot)
+
+			if item := p.Equipment[slot]; item != nil {
+				writeText(x+9, 3+i, -1, "%s", item.Rarity().Fg, item.Rarity().Bg, item.Name())
+			} else {
+				writeText(x+9, 3+i, -1, "nothing", CurrentTheme.Fg("dim"), bg)
+			}
+		}
+
+		if len(p.Inventory) > 0 {
+			renderTooltip(p.Game.Known, x, 9, 40, p.Inventory[cursor])
+		}
+
+		y := 4 + Conf.InventorySize
+		writeText(1, y, -1, "%s", fg, bg, status)
+		writeText(1, y+2, -1, "^B▲▼^! to choose an item, ^BE^! to equip or unequip it, ^BU^! to use it, ^BD^! to drop it, ^BI^! to inspect it", fg, bg)
+		writeText(1, y+3, -1, "^BESC^! to close your inventory", fg, bg)
+
+		termbox.Flush()
+
+		evt := termbox.PollEvent()
+		if evt.Type != termbox.EventKey {
+			continue
+		}
+
+		if evt.Key == termbox.KeyEsc {
+			return
+		}
+
+		switch evt.Key {
+		case termbox.KeyArrowUp:
+			cursor--
+			continue
+		case termbox.KeyArrowDown:
+			cursor++
+			continue
+		}
+
+		if len(p.Inventory) == 0 {
+			continue
+		}
+
+		item := p.Inventory[cursor]
+
+		switch evt.Ch {
+		case 'e', 'E':
+			if p.Equipped(item) {
+				if p.Unequip(item.Type().Kind.Slot) {
+					status = "You take off the " + item.Name() + "."
+				} else {
+					status = "You can't take off the " + item.Name() + ". It's cursed!"
+				}
+			} else if p.Equip(item) {
+				status = "You equip the " + item.Name() + "."
+				if cursed(item) {
+					status += " You feel a sudden chill..."
+				}
+			} else {
+				status = "You can't equip that."
+			}
+
+		case 'u', 'U':
+			status = p.UseItem(item)
+
+		case 'd', 'D':
+			status = p.Drop(item)
+
+		case 'i', 'I':
+			status = p.InspectItem(item)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package lib
 
 import (
+	"strings"
+
 	"github.com/nsf/termbox-go"
 )
 
@@ -9,23 +11,41 @@ type (
 	// item has a 'quality' which is calculated by analysing its attributes
 	// like type and rarity.
 	Item interface {
+		Name() string
 		Type() ItemType
 		Rarity() ItemRarity
 		Modifiers() []ItemModifier
 		Quality() int
+		Stats() Stats
 	}
 
 	// ItemKind is the type of a type, e.g. "weapon", "food"
 	ItemKind struct {
 		Name    string
 		Quality int
+		Slot    string // the equipment slot items of this kind go in, if any
+
+		// Appearances are what types of this kind can look like before
+		// they're identified, e.g. "murky". Unidentified is a format string
+		// for the name of an unidentified item, e.g. "%s potion". Kinds with
+		// no appearances are always identified.
+		Appearances  []string
+		Unidentified string
 	}
 
 	// ItemType is the type of an item, e.g. "sword"
 	ItemType struct {
-		Name    string
-		Kind    ItemKind
-		Quality int // note this is as well as the quality for the item kind
+		Name        string
+		Kind        ItemKind
+		Quality     int // note this is as well as the quality for the item kind
+		Glyph       string
+		Description string
+
+		// Stats are the bonuses the item gives to whoever is using it.
+		Stats Stats
+
+		// Use says what happens when the item is used, if it's a consumable.
+		Use *ItemUse
 	}
 
 	// ItemRarity indicates how rare an item is
@@ -34,6 +54,7 @@ type (
 		Fg      termbox.Attribute
 		Bg      termbox.Attribute
 		Quality int
+		Weight  int // how likely an item is to be of this rarity, relative to the others
 	}
 
 	// ItemModifier modifies the item in some way, e.g. "broken"
@@ -41,13 +62,149 @@ type (
 		Name    string
 		Quality int
 
-		// Modify is called when the modifier is attached to an object.
-		Modify func(i *Item, g *Game)
+		// Stats are added onto the stats of the item's type.
+		Stats Stats
+
+		// Chance is the probability of the modifier being put on a randomly
+		// generated item, and Kinds are the names of the kinds of item it can
+		// be put on. If Kinds is empty, it can go on anything.
+		Chance float64
+		Kinds  []string
+
+		// Hidden modifiers are magical, and aren't obvious from looking at
+		// an item, so they need to be identified.
+		Hidden bool
+
+		// Modify is called when the modifier is attached to a new item.
+		Modify func(e *ModifyEvent)
 
 		// OnUse is used differently for different kinds of item. For example,
-		// a weapon will call this when it's attacked with.
-		OnUse func(i *Item, g *Game, data map[string]interface{})
+		// a weapon will call this when it's attacked with, and a potion when
+		// it's drunk.
+		OnUse func(e *UseEvent)
+
+		// OnTick is called every turn while the item is being carried.
+		OnTick func(e *TickEvent)
+	}
 
-		OnTick func(i *Item, g *Game)
+	// Stats is a set of bonuses to the player's stats.
+	Stats struct {
+		Attack  int `yaml:"attack"`
+		Defense int `yaml:"defense"`
+		Magic   int `yaml:"magic"`
+		Health  int `yaml:"health"`
+		Speed   int `yaml:"speed"`
+	}
+
+	// ItemUse describes what happens when a consumable item is used.
+	ItemUse struct {
+		Heal     int    `yaml:"heal"`
+		Food     int    `yaml:"food"` // how much the item fills the player up
+		Effect   string `yaml:"effect"`
+		Turns    int    `yaml:"turns"`
+		Potency  int    `yaml:"potency"`
+		Cure     string `yaml:"cure"`     // an effect which the item gets rid of
+		Identify bool   `yaml:"identify"` // whether it identifies another item
+	}
+
+	// A BasicItem is an item put together from the types, rarities and
+	// modifiers in the item catalogue.
+	BasicItem struct {
+		ItemType   ItemType
+		ItemRarity ItemRarity
+		Mods       []ItemModifier
+
+		// Bonus is added onto the item's stats, and is set by its modifiers'
+		// Modify hooks.
+		Bonus Stats
+
+		// Cursed items can't be unequipped.
+		Cursed bool
+
+		// Inspected is set once the player has studied the item to try to
+		// identify it, since they only get one go.
+		Inspected bool
+
+		// known is what the player knows about the items in the run this
+		// one belongs to, which decides what it's called.
+		known *Knowledge
 	}
 )
+
+// newBasicItem makes a new item for a level at the given depth, running the
+// Modify hooks of each of its modifiers.
+func newBasicItem(k *Knowledge, t ItemType, r ItemRarity, mods []ItemModifier, depth int) *BasicItem {
+	item := &BasicItem{
+		ItemType:   t,
+		ItemRarity: r,
+		Mods:       mods,
+		known:      k,
+	}
+
+	for _, mod := range mods {
+		if mod.Modify != nil {
+			mod.Modify(&ModifyEvent{
+				Item:  item,
+				Depth: depth,
+			})
+		}
+	}
+
+	return item
+}
+
+// Name returns the name of an item, including its modifiers, e.g. "sharp
+// sword". Anything the player hasn't identified yet is called by its
+// appearance instead, e.g. "runed murky potion".
+func (b *BasicItem) Name() string {
+	words := []string{}
+
+	for _, mod := range b.Mods {
+		words = append(words, b.known.modifierName(mod))
+	}
+
+	return strings.Join(append(words, b.known.typeName(b.ItemType)), " ")
+}
+
+// Type returns the type of an item
+func (b *BasicItem) Type() ItemType {
+	return b.ItemType
+}
+
+// Rarity returns the rarity of an item
+func (b *BasicItem) Rarity() ItemRarity {
+	return b.ItemRarity
+}
+
+// Modifiers returns the modifiers attached to an item
+func (b *BasicItem) Modifiers() []ItemModifier {
+	return b.Mods
+}
+
+// Quality returns the quality of an item, which is worked out from its
+// kind, type, rarity and modifiers.
+func (b *BasicItem) Quality() int {
+	return calculateQuality(b.ItemType, b.ItemRarity, b.Mods)
+}
+
+// Stats returns the stats the item gives, including its modifiers.
+func (b *BasicItem) Stats() Stats {
+	stats := b.ItemType.Stats.Add(b.Bonus)
+
+	for _, mod := range b.Mods {
+		stats = stats.Add(mod.Stats)
+	}
+
+	return stats
+}
+
+// Add returns the sum of two sets of stats.
+func (s Stats) Add(o Stats) Stats {
+	return Stats{
+		Attack:  s.Attack + o.Attack,
+		Defense: s.Defense + o.Defense,
+		Magic:   s.Magic + o.Magic,
+		Health:  s.Health + o.Health,
+		Speed:   s.Speed + o.Speed,
+	}
+}
@@ -0,0 +1,253 @@
+package lib
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nsf/termbox-go"
+)
+
+// An Action is something the player can do by pressing a key.
+type Action struct {
+	Name     string
+	Category string
+	Help     string
+
+	// Keys are the keys which do the action unless the config says
+	// otherwise. See keyName for what they're called.
+	Keys []string
+
+	// Run does the action. The quit action doesn't have one, since it's up
+	// to the main loop to stop the game.
+	Run func(g *Game)
+}
+
+// Actions is every action the player can do with the keyboard, in the
+// order they're shown in the help. It's filled in by init, since some of
+// the actions use it.
+var Actions []*Action
+
+// Keymap is the action each key does, made from the config and the
+// actions' default keys.
+var Keymap map[string]*Action
+
+// keyNames are the names of the keys which aren't letters.
+var keyNames = map[termbox.Key]string{
+	termbox.KeyArrowUp:    "up",
+	termbox.KeyArrowDown:  "down",
+	termbox.KeyArrowLeft:  "left",
+	termbox.KeyArrowRight: "right",
+	termbox.KeySpace:      "space",
+	termbox.KeyEnter:      "enter",
+	termbox.KeyTab:        "tab",
+	termbox.KeyEsc:        "esc",
+}
+
+// keyLabels are how some keys are shown to the player.
+var keyLabels = map[string]string{
+	"up":    "▲",
+	"down":  "▼",
+	"left":  "◀",
+	"right": "▶",
+}
+
+// keyName returns the name of a key, as it's written in the config. Letters
+// are called by their lower case letter, whether shift is held or not.
+func keyName(ch rune, key termbox.Key) string {
+	if ch != 0 {
+		return strings.ToLower(string(ch))
+	}
+
+	return keyNames[key]
+}
+
+// keyLabel returns how a key is shown to the player.
+func keyLabel(name string) string {
+	if l, ok := keyLabels[name]; ok {
+		return l
+	}
+
+	return strings.ToUpper(name)
+}
+
+// ActionFor returns the action a key does, or nil if it doesn't do anything.
+func ActionFor(ch rune, key termbox.Key) *Action {
+	return Keymap[keyName(ch, key)]
+}
+
+// keysFor returns the keys which do the named action, as they're shown to
+// the player, e.g. "?/H".
+func keysFor(name string) string {
+	labels := []string{}
+
+	for _, a := range Actions {
+		if a.Name != name {
+			continue
+		}
+
+		for _, k := range actionKeys(a) {
+			labels = append(labels, keyLabel(k))
+		}
+	}
+
+	return strings.Join(labels, "/")
+}
+
+// actionKeys returns the keys which do an action, which are the ones in the
+// config if it gives any.
+func actionKeys(a *Action) []string {
+	if keys, ok := Conf.Keys[a.Name]; ok {
+		return keys
+	}
+
+	return a.Keys
+}
+
+// step moves the player one tile and turns them to face that way, unless
+// they've only just moved.
+func (g *Game) step(dx, dy, dir int) {
+	if time.Now().Sub(g.LastMove).Seconds() <= 0.08 {
+		return
+	}
+
+	g.Player.Move(dx, dy)
+	g.Player.Direction = dir
+	g.LastMove = time.Now()
+}
+
+// Hints returns a list of the things it makes sense for the player to do
+// right now, and which keys do them, e.g. "S: trade" when they're facing a
+// merchant.
+func (p *Player) Hints() []string {
+	var (
+		hints    = []string{}
+		interact = "^w" + keysFor("interact") + "^!: "
+		x, y     = p.GetFacing()
+	)
+
+	if a := p.Game.Level.ActorAt(x, y); a != nil {
+		hints = append(hints, interact+"attack the "+a.Name())
+	} else {
+		switch t := p.Game.Level.At(x, y).(type) {
+		case *MerchantTile:
+			hints = append(hints, interact+"trade")
+		case *BoxTile:
+			hints = append(hints, interact+"push")
+		case *ChestTile:
+			if !t.Open {
+				hints = append(hints, interact+"open")
+			}
+		case *TrapTile:
+			if !t.Hidden && !t.Disarmed {
+				hints = append(hints, interact+"disarm")
+			}
+		}
+	}
+
+	if len(p.Game.Level.ItemsAt(p.X, p.Y)) > 0 {
+		hints = append(hints, "^w"+keysFor("pick-up")+"^!: pick up")
+	}
+
+	return append(hints, "^w"+keysFor("help")+"^!: help", "^w"+keysFor("quit")+"^!: exit")
+}
+
+// ShowHelp shows every action and the keys which do it, grouped by what
+// sort of action it is, and what the player can do right now. It's shown a
+// page at a time if it doesn't fit on the screen.
+func (g *Game) ShowHelp() {
+	var (
+		lines = []string{}
+		last  string
+	)
+
+	for _, a := range Actions {
+		if a.Category != last {
+			if last != "" {
+				lines = append(lines, "")
+			}
+
+			lines = append(lines, "^B"+a.Category+"^!")
+			last = a.Category
+		}
+
+		lines = append(lines, fmt.Sprintf("  ^w%-9s^! %s", keysFor(a.Name), a.Help))
+	}
+
+	lines = append(lines, "", "^BThe mouse^!",
+		"  click on a tile to travel there, or next to you to interact with it",
+		"  hover over a tile to see what it is",
+		"", "^BRight now^!")
+
+	for _, hint := range g.Player.Hints() {
+		lines = append(lines, "  "+hint)
+	}
+
+	g.OpenDialog(&PagedDialog{
+		Title: "Help",
+		Text:  strings.Join(lines, "\n"),
+	})
+}
+
+func init() {
+	Actions = []*Action{
+		{"move-up", "Moving", "move up", []string{"up"}, func(g *Game) { g.step(0, -1, 0) }},
+		{"move-down", "Moving", "move down", []string{"down"}, func(g *Game) { g.step(0, 1, 2) }},
+		{"move-left", "Moving", "move left", []string{"left"}, func(g *Game) { g.step(-1, 0, 3) }},
+		{"move-right", "Moving", "move right", []string{"right"}, func(g *Game) { g.step(1, 0, 1) }},
+		{"turn-up", "Moving", "turn to face up", []string{"i"}, func(g *Game) { g.Player.Turn(0) }},
+		{"turn-down", "Moving", "turn to face down", []string{"k"}, func(g *Game) { g.Player.Turn(2) }},
+		{"turn-left", "Moving", "turn to face left", []string{"j"}, func(g *Game) { g.Player.Turn(3) }},
+		{"turn-right", "Moving", "turn to face right", []string{"l"}, func(g *Game) { g.Player.Turn(1) }},
+
+		{"interact", "Doing things", "interact with the tile you're facing", []string{"s"}, func(g *Game) { g.Player.Interact() }},
+		{"inspect", "Doing things", "inspect the tile you're facing", []string{"d"}, func(g *Game) { g.Player.Inspect() }},
+		{"search", "Doing things", "search for traps", []string{"f"}, func(g *Game) { g.Player.Search() }},
+		{"pick-up", "Doing things", "pick up items", []string{"g"}, func(g *Game) { g.Player.PickUp() }},
+
+		{"inventory", "Looking around", "open your inventory", []string{"e"}, func(g *Game) { g.Player.ShowInventory() }},
+		{"overview", "Looking around", "see the whole level", []string{"m"}, func(g *Game) { g.ShowOverview() }},
+		{"look", "Looking around", "look around", []string{"x"}, func(g *Game) { g.Look() }},
+		{"describe", "Looking around", "describe your surroundings in words", []string{"n"}, func(g *Game) { g.DescribeSurroundings() }},
+		{"help", "Looking around", "show this help", []string{"?", "h"}, func(g *Game) { g.ShowHelp() }},
+
+		{"skip", "Game", "skip animations", []string{"space"}, func(g *Game) { g.SkipAnimations() }},
+		{"quit", "Game", "exit the game", []string{"esc"}, nil},
+	}
+}
+
+// buildKeymap makes the keymap from the config and the actions' default
+// keys. It fails if the config names an action which doesn't exist, or if
+// a key ends up doing more than one action.
+func buildKeymap() error {
+	for name := range Conf.Keys {
+		found := false
+
+		for _, a := range Actions {
+			if a.Name == name {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("keys: unknown action %q", name)
+		}
+	}
+
+	Keymap = make(map[string]*Action)
+
+	for _, a := range Actions {
+		for _, k := range actionKeys(a) {
+			k = strings.ToLower(k)
+
+			if other, ok := Keymap[k]; ok && other != a {
+				return fmt.Errorf("keys: %q does both %s and %s", k, other.Name, a.Name)
+			}
+
+			Keymap[k] = a
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package lib
+
+import (
+	"image"
+
+	"github.com/nsf/termbox-go"
+)
+
+// The sizes of the panels around the map. The stats are always shown, and
+// when there isn't room for everything the minimap is dropped first, then
+// the hints, and the message log gets whatever's left.
+const (
+	sidebarWidth    = 40
+	statsWidth      = 36
+	statsHeight     = 15
+	hintsWidth      = 30
+	hintsHeight     = 9
+	minLogWidth     = 30
+	minLogHeight    = 2
+	minSideMapWidth = 60
+)
+
+// A Rect is an area of the screen, in cells.
+type Rect struct {
+	X, Y, W, H int
+}
+
+// Empty checks whether a rect has no area, which means the panel it's for
+// isn't shown.
+func (r Rect) Empty() bool {
+	return r.W <= 0 || r.H <= 0
+}
+
+// Contains checks whether the cell at (x, y) is inside a rect.
+func (r Rect) Contains(x, y int) bool {
+	return x >= r.X && y >= r.Y && x < r.X+r.W && y < r.Y+r.H
+}
+
+// A Layout says where each panel of the game screen goes.
+type Layout struct {
+	Map, Stats, Minimap, Hints, Log Rect
+
+	// View is the part of the level which is shown in the map panel, in
+	// tiles. It follows the player around on levels which don't fit.
+	View image.Rectangle
+}
+
+// Layout works out where everything goes on the game screen, from the size
+// of the terminal.
+func (g *Game) Layout() Layout {
+	w, h := termbox.Size()
+	return g.layoutFor(w, h)
+}
+
+// layoutFor works out where everything goes on a w by h screen.
+func (g *Game) layoutFor(w, h int) Layout {
+	var (
+		l         Layout
+		levelW    = g.Level.Width() * 2
+		minimapW  = (g.Level.Width() + 1) / 2
+		minimapH  = (g.Level.Height() + 3) / 4
+		side      bool
+		mapBottom int
+	)
+
+	switch Conf.Sidebar {
+	case "side":
+		side = true
+	case "bottom":
+		side = false
+	default:
+		side = w-sidebarWidth-6 >= minInt(levelW, minSideMapWidth)
+	}
+
+	if side {
+		// The map is on the left, and the panels are stacked on top of each
+		// other to the right of it.
+		mw := maxInt(0, minInt(levelW, w-sidebarWidth-6)) &^ 1
+		l.Map = Rect{2, 1, mw, h - 2}
+
+		var (
+			x    = l.Map.X + l.Map.W + 2
+			pw   = w - x - 2
+			y    = 1
+			rest = h - 2
+		)
+
+		l.Stats = Rect{x, y, pw, minInt(statsHeight, rest)}
+		y += l.Stats.H + 1
+		rest -= l.Stats.H + 1
+
+		if rest >= minimapH+1+hintsHeight+1+minLogHeight {
+			l.Minimap = Rect{x, y, minimapW, minimapH}
+			y += minimapH + 1
+			rest -= minimapH + 1
+		}
+
+		if rest >= hintsHeight+1+minLogHeight {
+			l.Hints = Rect{x, y, pw, hintsHeight}
+			y += hintsHeight + 1
+			rest -= hintsHeight + 1
+		}
+
+		l.Log = Rect{x, y, pw, maxInt(0, rest)}
+		mapBottom = h - 1
+	} else {
+		// The map takes up the whole width, and the panels are side by side
+		// underneath it.
+		var (
+			ph   = minInt(statsHeight, h/2)
+			y    = h - ph - 1
+			x    = 2
+			rest = w - 4
+		)
+
+		l.Map = Rect{2, 1, maxInt(0, minInt(levelW, w-4)) &^ 1, y - 2}
+
+		l.Stats = Rect{x, y, minInt(statsWidth, rest), ph}
+		x += l.Stats.W + 2
+		rest -= l.Stats.W + 2
+
+		if ph >= minimapH && rest >= minimapW+2+hintsWidth+2+minLogWidth {
+			l.Minimap = Rect{x, y, minimapW, minimapH}
+			x += minimapW + 2
+			rest -= minimapW + 2
+		}
+
+		if rest >= hintsWidth+2+minLogWidth {
+			l.Hints = Rect{x, y, hintsWidth, ph}
+			x += hintsWidth + 2
+			rest -= hintsWidth + 2
+		}
+
+		l.Log = Rect{x, y, maxInt(0, rest), ph}
+		mapBottom = y - 1
+	}
+
+	l.Map.H = maxInt(0, minInt(l.Map.H, mapBottom-l.Map.Y))
+	l.View = g.camera(l.Map.W/2, l.Map.H)
+
+	return l
+}
+
+// camera returns the part of the level to show in a map panel which is vw
+// tiles wide and vh tiles high, keeping the player in the middle unless
+// they're near the edge of the level.
+func (g *Game) camera(vw, vh int) image.Rectangle {
+	vw, vh = minInt(vw, g.Level.Width()), minInt(vh, g.Level.Height())
+
+	x := clamp(g.Player.X-vw/2, 0, g.Level.Width()-vw)
+	y := clamp(g.Player.Y-vh/2, 0, g.Level.Height()-vh)
+
+	return image.Rect(x, y, x+vw, y+vh)
+}
+
+// mapOrigin returns where the top-left tile of the level would be drawn on
+// the screen, so that the tiles in the layout's view end up in its map panel.
+func (l Layout) mapOrigin() (x, y int) {
+	return l.Map.X - l.View.Min.X*2, l.Map.Y - l.View.Min.Y
+}
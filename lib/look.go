@@ -0,0 +1,169 @@
+package lib
+
+import (
+	"fmt"
+	"image"
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+// ScreenToMap converts a position on the screen to the coordinates of the
+// tile drawn there, returning false if there isn't a tile there.
+func (g *Game) ScreenToMap(sx, sy int) (x, y int, ok bool) {
+	l := g.Layout()
+	if !l.Map.Contains(sx, sy) {
+		return 0, 0, false
+	}
+
+	ox, oy := l.mapOrigin()
+	x, y = (sx-ox)/2, sy-oy
+
+	if !image.Pt(x, y).In(l.View) {
+		return 0, 0, false
+	}
+
+	return x, y, true
+}
+
+// Describe describes everything the player can see at (x, y): the tile,
+// anything lying on it, and anyone standing on it.
+func (g *Game) Describe(x, y int) string {
+	if !g.Level.Explored(x, y) {
+		return "You haven't explored there yet."
+	}
+
+	var (
+		p     = g.Player
+		parts = []string{}
+	)
+
+	if x == p.X && y == p.Y {
+		parts = append(parts, fmt.Sprintf("You, with %d/%d health.", p.Health, p.Effective().Health))
+	}
+
+	if a := g.Level.ActorAt(x, y); a != nil && (!Conf.FogOfWar || g.Level.CanSee(p.X, p.Y, x, y, p.viewRadius())) {
+		desc := fmt.Sprintf("The %s, with %d/%d health.", a.Name(), a.Health, a.MaxHealth)
+
+		if len(a.Effects.Active) > 0 {
+			names := []string{}
+			for _, eff := range a.Effects.Active {
+				names = append(names, eff.Name())
+			}
+
+			desc += " It's " + strings.Join(names, ", ") + "."
+		}
+
+		if !a.Awake {
+			desc += " It's asleep."
+		}
+
+		parts = append(parts, desc)
+	}
+
+	parts = append(parts, g.Level.At(x, y).Description())
+
+	return strings.Join(parts, " ")
+}
+
+// Look lets the player move a cursor around the level, with the arrow keys
+// or the mouse, to examine anything they can see. It blocks until they
+// press ESC.
+func (g *Game) Look() {
+	var (
+		fg, bg = termbox.ColorDefault, termbox.ColorDefault
+		cx, cy = g.Player.X, g.Player.Y
+	)
+
+	for {
+		termbox.Clear(fg, bg)
+		g.Render()
+
+		var (
+			l      = g.Layout()
+			ox, oy = l.mapOrigin()
+			sx, sy = ox + cx*2, oy + cy
+			r      = l.Log
+		)
+
+		if r.Empty() {
+			r = l.Stats
+		}
+
+		highlight(sx, sy)
+		highlight(sx+1, sy)
+
+		// The description of the tile takes the place of the message log.
+		clearRect(r.X, r.Y, r.W, r.H)
+		writeText(r.X, r.Y, -1, "^BLooking at (%d, %d)^!", fg, bg, cx, cy)
+		writeText(r.X, r.Y+1, r.X+r.W-1, "%s", fg, bg, g.Describe(cx, cy))
+
+		if _, h := measureText(r.W, "%s", g.Describe(cx, cy)); r.H >= h+3 {
+			writeText(r.X, r.Y+r.H-1, -1, "^w▲▼◀▶^! or the mouse to move, ^wESC^! to stop looking", CurrentTheme.Fg("dim"), bg)
+		}
+
+		termbox.Flush()
+
+		switch evt := termbox.PollEvent(); evt.Type {
+		case termbox.EventKey:
+			switch evt.Key {
+			case termbox.KeyEsc, termbox.KeyEnter:
+				return
+			case termbox.KeyArrowUp:
+				cy--
+			case termbox.KeyArrowDown:
+				cy++
+			case termbox.KeyArrowLeft:
+				cx--
+			case termbox.KeyArrowRight:
+				cx++
+			}
+
+			if evt.Ch == 'x' || evt.Ch == 'X' {
+				return
+			}
+
+		case termbox.EventMouse:
+			if x, y, ok := g.ScreenToMap(evt.MouseX, evt.MouseY); ok {
+				cx, cy = x, y
+			}
+		}
+
+		view := g.Layout().View
+		cx = clamp(cx, view.Min.X, view.Max.X-1)
+		cy = clamp(cy, view.Min.Y, view.Max.Y-1)
+	}
+}
+
+// highlight shows the cell at (x, y) in reverse video.
+func highlight(x, y int) {
+	w, h := termbox.Size()
+	if x < 0 || y < 0 || x >= w || y >= h {
+		return
+	}
+
+	cell := termbox.CellBuffer()[y*w+x]
+	termbox.SetCell(x, y, cell.Ch, cell.Fg|termbox.AttrReverse, cell.Bg)
+}
+
+// clearRect blanks out a w by h area of the screen.
+func clearRect(x, y, w, h int) {
+	for j := y; j < y+h; j++ {
+		for i := x; i < x+w; i++ {
+			termbox.SetCell(i, j, ' ', termbox.ColorDefault, termbox.ColorDefault)
+		}
+	}
+}
+
+// clamp keeps n between lo and hi.
+func clamp(n, lo, hi int) int {
+	if n < lo {
+		return lo
+	}
+
+	if n > hi {
+		return hi
+	}
+
+	return n
+}
@@ -0,0 +1,226 @@
+package lib
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// maxLootDepth is how deeply loot tables can be nested inside each other,
+// which stops a table which refers to itself from looping forever.
+const maxLootDepth = 8
+
+type (
+	// A LootTable says what can be found in a chest, box, or on a defeated
+	// monster. Every guaranteed entry is always dropped, and then Rolls
+	// entries are picked from Entries, weighted by their weights.
+	LootTable struct {
+		Rolls      int         `yaml:"rolls"`
+		Guaranteed []LootEntry `yaml:"guaranteed"`
+		Entries    []LootEntry `yaml:"entries"`
+	}
+
+	// A LootEntry is a single possible drop in a loot table. It can be any
+	// combination of money, experience and an item, or another loot table to
+	// roll on. An entry with none of these is a drop of nothing.
+	LootEntry struct {
+		Weight int `yaml:"weight"`
+
+		// Money and Experience are [min, max] ranges.
+		Money      []int `yaml:"money"`
+		Experience []int `yaml:"xp"`
+
+		// Item is the name of an item type to drop, or "any" for a random
+		// item. Kind drops a random item of the given kind instead. Rarity is
+		// the lowest rarity the item can be.
+		Item   string `yaml:"item"`
+		Kind   string `yaml:"kind"`
+		Rarity string `yaml:"rarity"`
+
+		// Table is the name of another loot table to roll on. The entry's
+		// rarity is passed on to items from the other table.
+		Table string `yaml:"table"`
+	}
+
+	// Loot is the result of rolling on a loot table.
+	Loot struct {
+		Money      int
+		Experience int
+		Items      []Item
+	}
+)
+
+// lootTableNames are the loot tables which the game itself rolls on, so
+// they have to be in the config.
+var lootTableNames = []string{"chest", "rare-chest", "box", "boss"}
+
+// checkLootTables makes sure the loot tables the game rolls on are there,
+// and that every table, item type, kind and rarity the entries refer to
+// exists.
+func (c *Catalogue) checkLootTables(tables map[string]LootTable) error {
+	for _, name := range lootTableNames {
+		if _, ok := tables[name]; !ok {
+			return fmt.Errorf("missing loot table %q", name)
+		}
+	}
+
+	for name, t := range tables {
+		if t.Rolls < 0 {
+			return fmt.Errorf("loot table %s: negative rolls", name)
+		}
+
+		for _, e := range append(append([]LootEntry{}, t.Guaranteed...), t.Entries...) {
+			if err := c.checkLootEntry(e, tables); err != nil {
+				return fmt.Errorf("loot table %s: %v", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkLootEntry makes sure everything a loot entry refers to exists.
+func (c *Catalogue) checkLootEntry(e LootEntry, tables map[string]LootTable) error {
+	if e.Weight < 0 {
+		return fmt.Errorf("negative weight")
+	}
+
+	if e.Item != "" && e.Item != "any" {
+		if _, ok := c.Types[e.Item]; !ok {
+			return fmt.Errorf("unknown item type %q", e.Item)
+		}
+	}
+
+	if e.Kind != "" {
+		if _, ok := c.Kinds[e.Kind]; !ok {
+			return fmt.Errorf("unknown kind %q", e.Kind)
+		}
+	}
+
+	if e.Rarity != "" && !c.hasRarity(e.Rarity) {
+		return fmt.Errorf("unknown rarity %q", e.Rarity)
+	}
+
+	if e.Table != "" {
+		if _, ok := tables[e.Table]; !ok {
+			return fmt.Errorf("unknown loot table %q", e.Table)
+		}
+	}
+
+	return nil
+}
+
+// RollLoot rolls on the named loot table for a level at the given depth.
+// Any items are made for the run whose knowledge is k.
+func RollLoot(k *Knowledge, table string, depth int) Loot {
+	var loot Loot
+	loot.roll(k, table, "", depth, 0)
+	return loot
+}
+
+// roll rolls on a loot table, adding the results to the loot. minRarity is
+// the lowest rarity any items can be, if the entry which led to this table
+// didn't say.
+func (l *Loot) roll(k *Knowledge, name, minRarity string, depth, nesting int) {
+	table, ok := Conf.LootTables[name]
+	if !ok || nesting > maxLootDepth {
+		return
+	}
+
+	for _, e := range table.Guaranteed {
+		l.add(k, e, minRarity, depth, nesting)
+	}
+
+	total := 0
+	for _, e := range table.Entries {
+		total += e.Weight
+	}
+
+	if total <= 0 {
+		return
+	}
+
+	for i := 0; i < table.Rolls; i++ {
+		n := rand.Intn(total)
+
+		for _, e := range table.Entries {
+			if n < e.Weight {
+				l.add(k, e, minRarity, depth, nesting)
+				break
+			}
+
+			n -= e.Weight
+		}
+	}
+}
+
+// add adds a single loot entry to the loot.
+func (l *Loot) add(k *Knowledge, e LootEntry, minRarity string, depth, nesting int) {
+	l.Money += randRange(e.Money)
+	l.Experience += randRange(e.Experience)
+
+	if e.Rarity != "" {
+		minRarity = e.Rarity
+	}
+
+	switch {
+	case e.Item == "any":
+		l.Items = append(l.Items, Items.RandomItemOf(k, "", minRarity, depth))
+	case e.Item != "":
+		if item, err := Items.NewItemAt(k, e.Item, minRarity, depth); err == nil {
+			l.Items = append(l.Items, item)
+		}
+	case e.Kind != "":
+		l.Items = append(l.Items, Items.RandomItemOf(k, e.Kind, minRarity, depth))
+	}
+
+	if e.Table != "" {
+		l.roll(k, e.Table, minRarity, depth, nesting+1)
+	}
+}
+
+// randRange picks a random number from a [min, max] range. An empty range
+// is zero, and a range with one number is just that number.
+func randRange(r []int) int {
+	switch len(r) {
+	case 0:
+		return 0
+	case 1:
+		return r[0]
+	default:
+		if r[1] <= r[0] {
+			return r[0]
+		}
+
+		return r[0] + rand.Intn(r[1]-r[0]+1)
+	}
+}
+
+// giveLoot gives the money and experience from some loot to the player,
+// and spills its items onto the floor around (x, y). It returns a message
+// saying what they found and where, e.g. "in the chest".
+func (g *Game) giveLoot(where string, x, y int, l Loot) string {
+	msg := fmt.Sprintf("You find nothing %s.", where)
+
+	if l.Money > 0 {
+		g.Player.Money += l.Money
+		msg = fmt.Sprintf("You find £%d %s.", l.Money, where)
+	}
+
+	if len(l.Items) > 0 {
+		g.Level.Spill(x, y, l.Items)
+
+		if l.Money == 0 {
+			msg = ""
+		} else {
+			msg += " "
+		}
+
+		msg += fmt.Sprintf("Out falls %s.", describeItems(l.Items))
+	}
+
+	if l.Experience > 0 {
+		g.Player.GainExperience(l.Experience)
+	}
+
+	return msg
+}
@@ -1,6 +1,7 @@
 package lib
 
 import (
+	"image"
 	"math/rand"
 )
 
@@ -11,6 +12,17 @@ type Map struct {
 
 	// Tiles stores the tiles in a 2d matrix.
 	Tiles [][]Tile
+
+	// Actors stores the creatures living on the level.
+	Actors []*Actor
+
+	// Entrance is where the player should arrive on the level. If it's
+	// nil, they'll arrive on a random floor tile.
+	Entrance *image.Point
+
+	// Seen stores which tiles the player has explored, in the same layout
+	// as Tiles. It's made the first time the player looks around.
+	Seen [][]bool
 }
 
 // Width returns the width of the map
@@ -33,6 +45,21 @@ func (m *Map) Set(x, y int, t Tile) {
 	m.Tiles[y][x] = t
 }
 
+// SpawnPoint picks the coordinates where the player should arrive on the
+// level.
+func (m *Map) SpawnPoint() (x, y int) {
+	if m.Entrance != nil {
+		return m.Entrance.X, m.Entrance.Y
+	}
+
+	for {
+		x, y = rand.Intn(m.Width()), rand.Intn(m.Height())
+		if walkableFloor(m.At(x, y)) && m.ActorAt(x, y) == nil {
+			return x, y
+		}
+	}
+}
+
 // Postprocess processes a Map, adding in interesting tiles such as boxes,
 // more defined walls, etc...
 func (m *Map) Postprocess() {
@@ -77,6 +104,16 @@ func (m *Map) Postprocess() {
 			}
 		}
 	}
+
+	chance := trapChance(m.Depth)
+
+	for y := 0; y < m.Height(); y++ {
+		for x := 0; x < m.Width(); x++ {
+			if m.At(x, y).Type() == TileFloor && rand.Float64() < chance {
+				m.Set(x, y, randomTrap())
+			}
+		}
+	}
 }
 
 // neighbours gets the number of neighbours of a cell which are of a
@@ -107,12 +144,23 @@ func (m *Map) neighbours(x, y int, types ...int) int {
 	return count
 }
 
-// Render renders a Map instance to the terminal at the given
-// coordinates
-func (m *Map) Render(x, y int) {
-	for i, row := range m.Tiles {
-		for j, tile := range row {
-			tile.Render(x+j*2, y+i)
+// Render renders the part of a Map instance inside view to the terminal,
+// with the top-left tile of the map at the given coordinates. Tiles which
+// haven't been explored are left dark.
+func (m *Map) Render(x, y int, view image.Rectangle) {
+	view = view.Intersect(image.Rect(0, 0, m.Width(), m.Height()))
+
+	for i := view.Min.Y; i < view.Max.Y; i++ {
+		for j := view.Min.X; j < view.Max.X; j++ {
+			if m.Explored(j, i) {
+				m.Tiles[i][j].Render(x+j*2, y+i)
+			}
 		}
 	}
 }
+
+// RenderAround renders only the tiles of a Map inside view which are within
+// radius tiles of (cx, cy), leaving the rest of the map dark.
+func (m *Map) RenderAround(x, y int, view image.Rectangle, cx, cy, radius int) {
+	m.Render(x, y, view.Intersect(image.Rect(cx-radius, cy-radius, cx+radius+1, cy+radius+1)))
+}
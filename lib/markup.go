@@ -0,0 +1,251 @@
+package lib
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+// Text drawn with writeText can contain codes, starting with a ^, which
+// change how the text after them looks:
+//
+//	^r ^g ^b ^c ^m ^y ^w ^k  red, green, blue, cyan, magenta, yellow, white
+//	                         or black text
+//	^B ^U ^R                 bold, underlined or reversed text
+//	^(fg) ^(fg/bg)           any colours, written like the colours in the
+//	                         config and theme files, e.g. ^(bold 0xd1/blue).
+//	                         Either half can be left empty to keep it as it is
+//	^!                       back to the colours the text started with
+//	^^                       a ^ on its own
+
+type (
+	// A Style is the colours and attributes some text is drawn with.
+	Style struct {
+		Fg, Bg termbox.Attribute
+	}
+
+	// A Span is a run of text which is all drawn in the same style.
+	Span struct {
+		Text  string
+		Style Style
+	}
+
+	// A textCell is a single character of text once it's been laid out,
+	// relative to where the text starts.
+	textCell struct {
+		X, Y  int
+		Ch    rune
+		Style Style
+	}
+)
+
+// markupColours are the colours which have their own one letter codes.
+var markupColours = map[rune]termbox.Attribute{
+	'r': termbox.ColorRed,
+	'g': termbox.ColorGreen,
+	'b': termbox.ColorBlue,
+	'c': termbox.ColorCyan,
+	'm': termbox.ColorMagenta,
+	'y': termbox.ColorYellow,
+	'w': termbox.ColorWhite,
+	'k': termbox.ColorBlack,
+}
+
+// parseMarkup splits text up into spans at each of the codes in it, with
+// fg and bg as the style it starts with. Codes which aren't understood are
+// left out.
+func parseMarkup(text string, fg, bg termbox.Attribute) []Span {
+	var (
+		spans = []Span{}
+		start = Style{fg, bg}
+		style = start
+		buf   strings.Builder
+		str   = []rune(text)
+	)
+
+	flush := func() {
+		if buf.Len() > 0 {
+			spans = append(spans, Span{buf.String(), style})
+			buf.Reset()
+		}
+	}
+
+	for i := 0; i < len(str); i++ {
+		if str[i] != '^' {
+			buf.WriteRune(str[i])
+			continue
+		}
+
+		if i+1 >= len(str) {
+			break
+		}
+
+		i++
+
+		if str[i] == '^' {
+			buf.WriteRune('^')
+			continue
+		}
+
+		flush()
+
+		switch code := str[i]; code {
+		case '!':
+			style = start
+		case 'B':
+			style.Fg |= termbox.AttrBold
+		case 'U':
+			style.Fg |= termbox.AttrUnderline
+		case 'R':
+			style.Fg |= termbox.AttrReverse
+		case '(':
+			end := i
+			for end < len(str) && str[end] != ')' {
+				end++
+			}
+
+			// A ^( which is never closed is left out, but the text after
+			// it isn't.
+			if end < len(str) {
+				style = parseStyleSpec(string(str[i+1:end]), style)
+				i = end
+			}
+		default:
+			if c, ok := markupColours[code]; ok {
+				style.Fg = style.Fg&^colourMask | c
+			}
+		}
+	}
+
+	flush()
+
+	return spans
+}
+
+// parseStyleSpec changes a style to the colours given in a ^(fg/bg) code.
+// If either colour can't be parsed, it's left as it was.
+func parseStyleSpec(spec string, style Style) Style {
+	parts := strings.SplitN(spec, "/", 2)
+
+	if strings.TrimSpace(parts[0]) != "" {
+		if fg, err := parseColour(parts[0]); err == nil {
+			style.Fg = fg
+		}
+	}
+
+	if len(parts) > 1 && strings.TrimSpace(parts[1]) != "" {
+		if bg, err := parseColour(parts[1]); err == nil {
+			style.Bg = bg
+		}
+	}
+
+	return style
+}
+
+// layoutText works out where each character of some spans goes when they
+// are wrapped to width columns, breaking lines between words where it can.
+// A width of 0 or less means the text is never wrapped. It also returns the
+// width and height of the area the text takes up.
+func layoutText(spans []Span, width int) (cells []textCell, w, h int) {
+	type styledRune struct {
+		ch    rune
+		style Style
+	}
+
+	var str []styledRune
+	for _, s := range spans {
+		for _, ch := range s.Text {
+			str = append(str, styledRune{ch, s.Style})
+		}
+	}
+
+	var (
+		x, y int
+
+		// wrapped is set when a line has just been wrapped, so that spaces
+		// at the start of the new line can be skipped.
+		wrapped bool
+	)
+
+	newline := func() {
+		x = 0
+		y++
+	}
+
+	for i := 0; i < len(str); i++ {
+		switch ch := str[i].ch; ch {
+		case '\n':
+			newline()
+			wrapped = false
+			h = y + 1
+
+		case ' ', '\t':
+			if width > 0 && x >= width {
+				newline()
+				wrapped = true
+			}
+
+			if wrapped {
+				continue
+			}
+
+			// Spaces are drawn, since they might have a background colour,
+			// but tabs just move along.
+			if ch == '\t' {
+				x += 4
+				if width > 0 && x > width {
+					x = width
+				}
+			} else {
+				cells = append(cells, textCell{x, y, ch, str[i].style})
+				x++
+			}
+
+			if x > w {
+				w = x
+			}
+
+			h = y + 1
+
+		default:
+			end := i
+			for end < len(str) && !strings.ContainsRune(" \t\n", str[end].ch) {
+				end++
+			}
+
+			// Words which won't fit on the end of the line go on the next
+			// one, unless they won't fit on a line of their own either.
+			if width > 0 && x > 0 && x+end-i > width {
+				newline()
+			}
+
+			for ; i < end; i++ {
+				if width > 0 && x >= width {
+					newline()
+				}
+
+				cells = append(cells, textCell{x, y, str[i].ch, str[i].style})
+				x++
+
+				if x > w {
+					w = x
+				}
+			}
+
+			i--
+			wrapped = false
+			h = y + 1
+		}
+	}
+
+	return cells, w, h
+}
+
+// measureText works out how many columns and rows some text would take up
+// if it was drawn with writeText, wrapped to width columns, without drawing
+// it.
+func measureText(width int, text string, args ...interface{}) (w, h int) {
+	_, w, h = layoutText(parseMarkup(fmt.Sprintf(text, args...), 0, 0), width)
+	return w, h
+}
@@ -0,0 +1,184 @@
+package lib
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nsf/termbox-go"
+)
+
+// defFg and defBg are the colours the text in the tests starts with.
+const (
+	defFg = termbox.ColorDefault
+	defBg = termbox.ColorDefault
+)
+
+func TestParseMarkup(t *testing.T) {
+	start := Style{defFg, defBg}
+
+	tests := []struct {
+		name string
+		text string
+		want []Span
+	}{
+		{"empty", "", []Span{}},
+		{"plain", "hello", []Span{{"hello", start}}},
+		{"colour", "a ^rb", []Span{
+			{"a ", start},
+			{"b", Style{termbox.ColorRed, defBg}},
+		}},
+		{"colour keeps attributes", "^B^rb", []Span{
+			{"b", Style{termbox.ColorRed | termbox.AttrBold, defBg}},
+		}},
+		{"reset", "^ra^!b", []Span{
+			{"a", Style{termbox.ColorRed, defBg}},
+			{"b", start},
+		}},
+		{"escaped caret", "a^^b", []Span{{"a^b", start}}},
+		{"escaped caret before a code", "^^r", []Span{{"^r", start}}},
+		{"caret at the end", "a^", []Span{{"a", start}}},
+		{"unknown code", "a^zb", []Span{{"a", start}, {"b", start}}},
+		{"fg and bg", "^(red/blue)x", []Span{
+			{"x", Style{termbox.ColorRed, termbox.ColorBlue}},
+		}},
+		{"fg only", "^(bold 0xd1)x", []Span{
+			{"x", Style{0xd1 | termbox.AttrBold, defBg}},
+		}},
+		{"bg only", "^(/green)x", []Span{
+			{"x", Style{defFg, termbox.ColorGreen}},
+		}},
+		{"bad colour", "^(nope/blue)x", []Span{
+			{"x", Style{defFg, termbox.ColorBlue}},
+		}},
+		{"unclosed", "a ^(red b c", []Span{{"a ", start}, {"red b c", start}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseMarkup(tt.text, defFg, defBg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseMarkup(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+// cellLines draws laid out cells onto lines of text, with a . wherever
+// nothing was drawn, so that spaces which are drawn can be told apart from
+// gaps.
+func cellLines(cells []textCell, w, h int) []string {
+	grid := make([][]rune, h)
+	for y := range grid {
+		grid[y] = []rune(strings.Repeat(".", w))
+	}
+
+	for _, c := range cells {
+		grid[c.Y][c.X] = c.Ch
+	}
+
+	lines := make([]string, h)
+	for y, row := range grid {
+		lines[y] = string(row)
+	}
+
+	return lines
+}
+
+func TestLayoutText(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		width int
+		want  []string
+	}{
+		{"empty", "", 10, []string{}},
+		{"fits", "hello", 10, []string{"hello"}},
+		{"word wrap", "the quick brown fox jumps over the lazy dog", 10, []string{
+			"the quick ",
+			"brown fox ",
+			"jumps over",
+			"the lazy .",
+			"dog.......",
+		}},
+		{"long word", "supercalifragilistic ok", 10, []string{
+			"supercalif",
+			"ragilistic",
+			"ok........",
+		}},
+		{"long word after another", "a abcdefghijkl", 5, []string{
+			"a ...",
+			"abcde",
+			"fghij",
+			"kl...",
+		}},
+		{"spaces after a wrap", "abcde   fg", 5, []string{
+			"abcde",
+			"fg...",
+		}},
+		{"spaces after a newline", "ab\n  cd", 5, []string{
+			"ab..",
+			"  cd",
+		}},
+		{"newlines", "a\n\nb", 5, []string{"a", ".", "b"}},
+		{"tab", "a\tb", 10, []string{"a....b"}},
+		{"tab clamped to the width", "abc\tde", 5, []string{
+			"abc..",
+			"de...",
+		}},
+		{"no wrap", "the quick brown fox", 0, []string{"the quick brown fox"}},
+		{"no wrap negative", "the quick brown fox", -1, []string{"the quick brown fox"}},
+		{"no wrap tab", "abc\tde", 0, []string{"abc....de"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cells, w, h := layoutText(parseMarkup(tt.text, defFg, defBg), tt.width)
+
+			if got := cellLines(cells, w, h); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("layoutText(%q, %d) =\n%q\nwant\n%q", tt.text, tt.width, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLayoutTextStyles(t *testing.T) {
+	cells, _, _ := layoutText(parseMarkup("a ^rbc", defFg, defBg), 0)
+
+	want := []textCell{
+		{0, 0, 'a', Style{defFg, defBg}},
+		{1, 0, ' ', Style{defFg, defBg}},
+		{2, 0, 'b', Style{termbox.ColorRed, defBg}},
+		{3, 0, 'c', Style{termbox.ColorRed, defBg}},
+	}
+
+	if !reflect.DeepEqual(cells, want) {
+		t.Errorf("got %v, want %v", cells, want)
+	}
+}
+
+func TestMeasureText(t *testing.T) {
+	tests := []struct {
+		name  string
+		width int
+		text  string
+		args  []interface{}
+		w, h  int
+	}{
+		{"empty", 10, "", nil, 0, 0},
+		{"codes take no room", 10, "^rred^! ^^", nil, 5, 1},
+		{"args", 10, "%d gold", []interface{}{250}, 8, 1},
+		{"markup in args", 20, "%s", []interface{}{"^Bbold^!"}, 4, 1},
+		{"wrapped", 10, "the quick brown fox jumps over the lazy dog", nil, 10, 5},
+		{"long word", 4, "abcdefghij", nil, 4, 3},
+		{"no wrap", 0, "the quick brown fox", nil, 19, 1},
+		{"no wrap negative", -5, "a\nbcd", nil, 3, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w, h := measureText(tt.width, tt.text, tt.args...); w != tt.w || h != tt.h {
+				t.Errorf("measureText(%d, %q) = %d, %d, want %d, %d", tt.width, tt.text, w, h, tt.w, tt.h)
+			}
+		})
+	}
+}
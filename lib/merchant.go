@@ -0,0 +1,282 @@
+package lib
+
+import (
+	"math/rand"
+
+	"github.com/nsf/termbox-go"
+)
+
+// The bounds of a merchant's reputation with the player
+const (
+	minReputation = -5
+	maxReputation = 5
+)
+
+// stock returns the merchant's stock, generating it the first time the
+// merchant is traded with. Every merchant has some food for sale. The stock
+// stays the same for as long as the player is on the level.
+func (f *MerchantTile) stock(k *Knowledge, depth int) []Item {
+	if f.Stock == nil {
+		f.Stock = make([]Item, 0, Conf.MerchantStock+Conf.MerchantFood)
+		f.Discounts = make(map[Item]float64)
+
+		for i := 0; i < Conf.MerchantFood; i++ {
+			f.Stock = append(f.Stock, Items.RandomItemOf(k, "food", "", depth))
+		}
+
+		for i := 0; i < Conf.MerchantStock; i++ {
+			f.Stock = append(f.Stock, Items.RandomItem(k, depth))
+		}
+	}
+
+	return f.Stock
+}
+
+// priceFactor returns how much the merchant's prices are multiplied by,
+// based on how much they like the player.
+func (f *MerchantTile) priceFactor() float64 {
+	return 1 - 0.05*float64(f.Reputation)
+}
+
+// buyPrice returns how much the merchant is charging for an item.
+func (f *MerchantTile) buyPrice(i Item) int {
+	price := float64(BuyPrice(i)) * f.priceFactor() * (1 - f.Discounts[i])
+
+	if price < 1 {
+		return 1
+	}
+
+	return int(price)
+}
+
+// sellPrice returns how much the merchant will pay for an item.
+func (f *MerchantTile) sellPrice(i Item) int {
+	return int(float64(SellPrice(i)) * (2 - f.priceFactor()))
+}
+
+// changeReputation changes how much the merchant likes the player, keeping
+// it within bounds.
+func (f *MerchantTile) changeReputation(delta int) {
+	f.Reputation += delta
+
+	if f.Reputation < minReputation {
+		f.Reputation = minReputation
+	}
+
+	if f.Reputation > maxReputation {
+		f.Reputation = maxReputation
+	}
+}
+
+// buy sells an item from the merchant's stock to the player.
+func (f *MerchantTile) buy(p *Player, i Item) string {
+	price := f.buyPrice(i)
+
+	if p.Money < price {
+		return "You can't afford that."
+	}
+
+	if !p.AddItem(i) {
+		return "You can't carry any more."
+	}
+
+	p.Money -= price
+
+	for n, it := range f.Stock {
+		if it == i {
+			f.Stock = append(f.Stock[:n], f.Stock[n+1:]...)
+			break
+		}
+	}
+
+	delete(f.Discounts, i)
+	f.changeReputation(1)
+
+	return "You buy the " + i.Name() + "."
+}
+
+// sell sells an item from the player's inventory to the merchant.
+func (f *MerchantTile) sell(p *Player, i Item) string {
+	if !p.RemoveItem(i) {
+		return "You can't let go of the " + i.Name() + ". It's cursed!"
+	}
+
+	p.Money += f.sellPrice(i)
+	f.Stock = append(f.Stock, i)
+
+	return "You sell the " + i.Name() + "."
+}
+
+// haggle tries to get the merchant to lower their price for an item. If it
+// works, the item gets cheaper, but if it doesn't, the merchant likes the
+// player a bit less. A merchant will only haggle over each item once.
+func (f *MerchantTile) haggle(p *Player, i Item) string {
+	if _, ok := f.Discounts[i]; ok {
+		return "\"I've already told you my price for that.\""
+	}
+
+	chance := Conf.HaggleChance + float64(p.Effective().Magic)*0.03 + float64(f.Reputation)*0.05
+
+	if rand.Float64() < chance {
+		f.Discounts[i] = Conf.HaggleDiscount
+		return "\"Fine, fine. For you, a special price.\""
+	}
+
+	f.Discounts[i] = 0
+	f.changeReputation(-1)
+
+	return "\"Are you trying to rob me?!\""
+}
+
+// identify identifies one of the player's items, for a price.
+func (f *MerchantTile) identify(p *Player, i Item, depth int) string {
+	if p.Game.Known.Identified(i) {
+		return "\"You know full well what that is.\""
+	}
+
+	price := Conf.IdentifyPrice * depth
+	if p.Money < price {
+		return "\"Knowledge isn't free, friend.\""
+	}
+
+	p.Money -= price
+	before := i.Name()
+	p.Game.Known.Identify(i)
+
+	return "\"Your " + before + "? That's " + withArticle(i.Name()) + ".\""
+}
+
+// trade shows the trading screen, where the player can buy items from a
+// merchant and sell their own. It blocks until they leave.
+func (f *MerchantTile) trade(g *Game) {
+	var (
+		p       = g.Player
+		fg, bg  = termbox.ColorDefault, termbox.ColorDefault
+		selling = false
+		cursor  = 0
+		status  = "\"Welcome, welcome! Take a look around.\""
+
+		// rows is how many items fit in each list. The merchant's stock can
+		// grow past that as the player sells to them, so the lists scroll.
+		rows = Conf.InventorySize
+	)
+
+	f.stock(g.Known, g.Level.Depth)
+
+	for {
+		items := f.Stock
+		if selling {
+			items = p.Inventory
+		}
+
+		if cursor >= len(items) {
+			cursor = len(items) - 1
+		}
+
+		if cursor < 0 {
+			cursor = 0
+		}
+
+		termbox.Clear(fg, bg)
+
+		writeText(1, 1, -1, "^BMerchant^!", fg, bg)
+		writeText(30, 1, -1, "money: ^g£%d^!  reputation: %+d", fg, bg, p.Money, f.Reputation)
+
+		for col, list := range [][]Item{f.Stock, p.Inventory} {
+			var (
+				x      = 1 + col*40
+				active = (col == 1) == selling
+				title  = "For sale"
+			)
+
+			if col == 1 {
+				title = "Your items"
+			}
+
+			if active {
+				writeText(x, 3, -1, "^B%s^!", termbox.ColorCyan, bg, title)
+			} else {
+				writeText(x, 3, -1, "%s", CurrentTheme.Fg("dim"), bg, title)
+			}
+
+			// The active list scrolls to keep the cursor in sight.
+			first := 0
+			if active && cursor >= rows {
+				first = cursor - rows + 1
+			}
+
+			for i := first; i < len(list) && i-first < rows; i++ {
+				var (
+					item  = list[i]
+					row   = 5 + i - first
+					price = f.buyPrice(item)
+				)
+
+				if col == 1 {
+					price = f.sellPrice(item)
+				}
+
+				if active && i == cursor {
+					writeText(x, row, -1, ">", fg|termbox.AttrBold, bg)
+				}
+
+				writeText(x+2, row, -1, "%s", item.Rarity().Fg, item.Rarity().Bg, item.Name())
+				writeText(x+28, row, -1, "^g£%d^!", fg, bg, price)
+			}
+		}
+
+		if len(items) > 0 {
+			renderTooltip(g.Known, 81, 3, 36, items[cursor])
+		}
+
+		y := 6 + rows
+		writeText(1, y, -1, "%s", fg, bg, status)
+		writeText(1, y+2, -1, "^B▲▼^! to choose an item, ^BTAB^! to switch between buying and selling", fg, bg)
+		writeText(1, y+3, -1, "^BRETURN^! to buy or sell, ^BH^! to haggle, ^BI^! to identify (^g£%d^!), ^BESC^! to leave", fg, bg, Conf.IdentifyPrice*g.Level.Depth)
+
+		termbox.Flush()
+
+		evt := termbox.PollEvent()
+		if evt.Type != termbox.EventKey {
+			continue
+		}
+
+		switch {
+		case evt.Key == termbox.KeyEsc:
+			return
+
+		case evt.Key == termbox.KeyArrowUp:
+			cursor--
+
+		case evt.Key == termbox.KeyArrowDown:
+			cursor++
+
+		case evt.Key == termbox.KeyTab:
+			selling = !selling
+			cursor = 0
+
+		case len(items) == 0:
+			continue
+
+		case evt.Key == termbox.KeyEnter && selling:
+			status = f.sell(p, items[cursor])
+
+		case evt.Key == termbox.KeyEnter:
+			status = f.buy(p, items[cursor])
+
+		case evt.Ch == 'h' || evt.Ch == 'H':
+			if selling {
+				status = "\"You want me to pay *more*? Ha!\""
+			} else {
+				status = f.haggle(p, items[cursor])
+			}
+
+		case evt.Ch == 'i' || evt.Ch == 'I':
+			if selling {
+				status = f.identify(p, items[cursor], g.Level.Depth)
+			} else {
+				status = "\"I know what my own wares are.\""
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package lib
+
+import (
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+// The things a minimap cell can show, from most to least important. A
+// cell shows the most important thing in the block of tiles it covers.
+var minimapRoles = []string{
+	"minimap-player",
+	"minimap-trapdoor",
+	"minimap-merchant",
+	"minimap-floor",
+	"minimap-wall",
+}
+
+// renderMinimap draws a shrunk-down version of the level at (x, y), where
+// each cell covers a bw by bh block of tiles and is cellWidth columns wide.
+// Only explored tiles are shown.
+func (g *Game) renderMinimap(x, y, bw, bh, cellWidth int) {
+	m := g.Level
+
+	for cy := 0; cy*bh < m.Height(); cy++ {
+		for cx := 0; cx*bw < m.Width(); cx++ {
+			best := len(minimapRoles)
+
+			for ty := cy * bh; ty < (cy+1)*bh && ty < m.Height(); ty++ {
+				for tx := cx * bw; tx < (cx+1)*bw && tx < m.Width(); tx++ {
+					if i := g.minimapRank(tx, ty); i < best {
+						best = i
+					}
+				}
+			}
+
+			if best == len(minimapRoles) {
+				continue
+			}
+
+			role := CurrentTheme.Role(minimapRoles[best])
+
+			// The floor and walls are repeated to fill wide cells, but the
+			// markers are only drawn once.
+			if best >= 3 {
+				role.Glyph = strings.Repeat(role.Glyph, cellWidth)
+			} else {
+				role.Glyph += strings.Repeat(" ", cellWidth-1)
+			}
+
+			role.Render(x+cx*cellWidth, y+cy)
+		}
+	}
+}
+
+// minimapRank returns the index in minimapRoles of what the tile at (x, y)
+// should show up as on the minimap, or len(minimapRoles) if it shouldn't
+// show up at all.
+func (g *Game) minimapRank(x, y int) int {
+	if x == g.Player.X && y == g.Player.Y {
+		return 0
+	}
+
+	if !g.Level.Explored(x, y) {
+		return len(minimapRoles)
+	}
+
+	switch t := g.Level.At(x, y); {
+	case t.Type() == TileTrapdoor:
+		return 1
+	case t.Type() == TileMerchant:
+		return 2
+	case t.Type() == TileWall:
+		return 4
+	case t.Type() == TileOutside:
+		return len(minimapRoles)
+	default:
+		return 3
+	}
+}
+
+// ShowOverview shows the whole level on one screen, shrunk down if it
+// doesn't fit. It blocks until the player closes it.
+func (g *Game) ShowOverview() {
+	fg, bg := termbox.ColorDefault, termbox.ColorDefault
+
+	for {
+		termbox.Clear(fg, bg)
+
+		var (
+			w, h  = termbox.Size()
+			scale = 1
+		)
+
+		for (g.Level.Width()/scale*2 > w-4 || g.Level.Height()/scale > h-6) && scale < g.Level.Width() {
+			scale++
+		}
+
+		writeText(2, 1, -1, "^BLevel %d^!", fg, bg, g.Level.Depth)
+		g.renderMinimap(2, 3, scale, scale, 2)
+
+		y := 4 + (g.Level.Height()+scale-1)/scale
+		for i, role := range minimapRoles[:3] {
+			CurrentTheme.Role(role).Render(2+i*14, y)
+		}
+
+		writeText(4, y, -1, "you", fg, bg)
+		writeText(18, y, -1, "trapdoor", fg, bg)
+		writeText(32, y, -1, "merchant", fg, bg)
+		writeText(2, y+1, -1, "^BM^! or ^BESC^! to close", fg, bg)
+
+		termbox.Flush()
+
+		evt := termbox.PollEvent()
+		if evt.Type == termbox.EventKey && (evt.Key == termbox.KeyEsc || evt.Ch == 'm' || evt.Ch == 'M') {
+			return
+		}
+	}
+}
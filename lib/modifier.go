@@ -0,0 +1,150 @@
+package lib
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+type (
+	// A ModifyEvent is passed to a modifier's Modify hook when the modifier is
+	// put on a new item.
+	ModifyEvent struct {
+		Item  *BasicItem
+		Depth int // the depth of the level the item was made for
+	}
+
+	// A UseEvent is passed to a modifier's OnUse hook whenever its item is
+	// used: when the player attacks with it, or when they consume it.
+	UseEvent struct {
+		Item Item
+		Game *Game
+
+		// Target is the actor being attacked, or nil if the item is being
+		// consumed. Damage is how much damage the attack will do, which the
+		// hook can change.
+		Target *Actor
+		Damage int
+
+		// Message is shown to the player after the item's been used. Hooks
+		// can add to it to say what they did.
+		Message string
+	}
+
+	// A TickEvent is passed to a modifier's OnTick hook once every turn, for
+	// every item the player is carrying.
+	TickEvent struct {
+		Item     Item
+		Game     *Game
+		Equipped bool
+	}
+
+	// modifierHooks are the behaviours a modifier can have on top of the
+	// stats it gives.
+	modifierHooks struct {
+		Modify func(e *ModifyEvent)
+		OnUse  func(e *UseEvent)
+		OnTick func(e *TickEvent)
+	}
+)
+
+// builtinModifiers are the hooks which modifiers in the item catalogue can
+// use, by name.
+var builtinModifiers = map[string]modifierHooks{
+	// broken weapons sometimes glance off whatever they hit.
+	"broken": {
+		OnUse: func(e *UseEvent) {
+			if e.Target != nil && rand.Float64() < 0.25 {
+				e.Damage = maxInt(1, e.Damage/2)
+				e.say("Your %s glances off.", e.Item.Name())
+			}
+		},
+	},
+
+	// sharp weapons are sharper the deeper they're found, and sometimes land
+	// critical hits.
+	"sharp": {
+		Modify: func(e *ModifyEvent) {
+			e.Item.Bonus.Attack += e.Depth / 4
+		},
+
+		OnUse: func(e *UseEvent) {
+			if e.Target != nil && rand.Float64() < 0.2 {
+				e.Damage *= 2
+				e.say("A critical hit!")
+			}
+		},
+	},
+
+	// vampiric weapons heal the player by a quarter of the damage they do.
+	"vampiric": {
+		OnUse: func(e *UseEvent) {
+			if e.Target == nil {
+				return
+			}
+
+			heal := e.Damage / 4
+			if heal < 1 {
+				heal = 1
+			}
+
+			e.Game.Player.Heal(heal)
+			e.say("Your %s drinks %d health.", e.Item.Name(), heal)
+		},
+	},
+
+	// cursed items can't be taken off once they're equipped, and sometimes
+	// leave the player confused.
+	"cursed": {
+		Modify: func(e *ModifyEvent) {
+			e.Item.Cursed = true
+		},
+
+		OnTick: func(e *TickEvent) {
+			if e.Equipped && rand.Float64() < 0.02 {
+				e.Game.Player.Effects.Add(EffectConfusion, 3, 0)
+				e.Game.UI.SetMinibuf(fmt.Sprintf("Your %s whispers to you, and your head starts to spin.", e.Item.Name()), time.Second*4)
+			}
+		},
+	},
+}
+
+// say adds a sentence to the event's message.
+func (e *UseEvent) say(format string, args ...interface{}) {
+	if e.Message != "" {
+		e.Message += " "
+	}
+
+	e.Message += fmt.Sprintf(format, args...)
+}
+
+// useItem runs the OnUse hooks of every modifier on an item.
+func useItem(e *UseEvent) {
+	for _, mod := range e.Item.Modifiers() {
+		if mod.OnUse != nil {
+			mod.OnUse(e)
+		}
+	}
+}
+
+// tickItems runs the OnTick hooks of every modifier on every item the
+// player is carrying.
+func (g *Game) tickItems() {
+	for _, item := range g.Player.Inventory {
+		for _, mod := range item.Modifiers() {
+			if mod.OnTick != nil {
+				mod.OnTick(&TickEvent{
+					Item:     item,
+					Game:     g,
+					Equipped: g.Player.Equipped(item),
+				})
+			}
+		}
+	}
+}
+
+// cursed checks whether an item is cursed.
+func cursed(i Item) bool {
+	b, ok := i.(*BasicItem)
+	return ok && b.Cursed
+}
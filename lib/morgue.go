@@ -0,0 +1,155 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nsf/termbox-go"
+)
+
+// Die ends the run. Every item is identified, so the player can finally
+// see what they were carrying, then they're asked for a name to write on
+// the morgue file recording how it went. The run is over once they've
+// closed the death screen after that.
+func Die(g *Game) {
+	g.Known.IdentifyAll(Items)
+
+	g.OpenDialog(&InputDialog{
+		Title:     "You died",
+		Prompt:    fmt.Sprintf("Killed by %s on level %d. What name should go on your grave?", g.Player.CauseOfDeath, g.Level.Depth),
+		MaxLength: 30,
+		OnSubmit: func(name string, ok bool) {
+			if !ok || strings.TrimSpace(name) == "" {
+				name = "Anonymous"
+			}
+
+			path, err := writeMorgue(g, name)
+			if err != nil {
+				log.Printf("error writing morgue file: %v", err)
+				path = ""
+			}
+
+			g.OpenDialog(&PagedDialog{
+				Title:   "You died",
+				Text:    deathSummary(g, path),
+				OnClose: func() { g.Over = true },
+			})
+		},
+	})
+}
+
+// lastMessages returns the last n messages which were shown to the player.
+func (u *UI) lastMessages(n int) []string {
+	if len(u.Messages) < n {
+		return u.Messages
+	}
+
+	return u.Messages[len(u.Messages)-n:]
+}
+
+// writeMorgue writes a human-readable summary of the run to a new file in
+// the morgue directory, returning its path.
+func writeMorgue(g *Game, name string) (string, error) {
+	var (
+		buf       bytes.Buffer
+		p         = g.Player
+		now       = time.Now()
+		base, eff = p.Base(), p.Effective()
+	)
+
+	fmt.Fprintf(&buf, "Morgue file, written %s\n\n", now.Format("2 Jan 2006 15:04:05"))
+	fmt.Fprintf(&buf, "Name:           %s\n", name)
+	fmt.Fprintf(&buf, "Seed:           %d\n", g.Seed)
+	fmt.Fprintf(&buf, "Depth reached:  %d\n", g.Level.Depth)
+	fmt.Fprintf(&buf, "Turns taken:    %d\n", g.Turn)
+	fmt.Fprintf(&buf, "Cause of death: killed by %s\n\n", p.CauseOfDeath)
+
+	fmt.Fprintf(&buf, "Final stats\n")
+	fmt.Fprintf(&buf, "  level:      %d\n", p.Level)
+	fmt.Fprintf(&buf, "  experience: %d\n", p.Experience)
+	fmt.Fprintf(&buf, "  max health: %d (base %d)\n", eff.Health, base.Health)
+	fmt.Fprintf(&buf, "  attack:     %d (base %d)\n", eff.Attack, base.Attack)
+	fmt.Fprintf(&buf, "  defense:    %d (base %d)\n", eff.Defense, base.Defense)
+	fmt.Fprintf(&buf, "  magic:      %d (base %d)\n", eff.Magic, base.Magic)
+	fmt.Fprintf(&buf, "  speed:      %+d\n\n", eff.Speed)
+
+	fmt.Fprintf(&buf, "Inventory\n")
+	fmt.Fprintf(&buf, "  money: £%d\n", p.Money)
+	for _, item := range p.Inventory {
+		equipped := ""
+		if p.Equipped(item) {
+			equipped = " (equipped)"
+		}
+
+		fmt.Fprintf(&buf, "  %s %s%s\n", item.Rarity().Name, item.Name(), equipped)
+	}
+	fmt.Fprintf(&buf, "\n")
+
+	fmt.Fprintf(&buf, "Last messages\n")
+	for _, msg := range g.UI.lastMessages(Conf.MorgueMessages) {
+		fmt.Fprintf(&buf, "  %s\n", msg)
+	}
+
+	if err := os.MkdirAll(Conf.MorgueDir, 0755); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(Conf.MorgueDir, fmt.Sprintf("morgue-%s.txt", now.Format("20060102-150405")))
+
+	return path, ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// deathSummary says how the run went, for the death screen, and where its
+// morgue file was written if it could be.
+func deathSummary(g *Game, path string) string {
+	var (
+		p     = g.Player
+		lines = []string{
+			fmt.Sprintf("Killed by %s on level ^B%d^!, after %d turns.", p.CauseOfDeath, g.Level.Depth, g.Turn),
+			fmt.Sprintf("You reached level ^b%d^! and had ^g£%d^!.", p.Level, p.Money),
+			"",
+		}
+	)
+
+	for _, msg := range g.UI.lastMessages(5) {
+		lines = append(lines, "  "+msg)
+	}
+
+	if path != "" {
+		lines = append(lines, "", "A morgue file has been written to ^B"+strings.Replace(path, "^", "^^", -1)+"^!")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Menu shows the main menu, and returns true if the player wants to start a
+// new game or false if they want to quit.
+func Menu() bool {
+	fg, bg := termbox.ColorDefault, termbox.ColorDefault
+
+	termbox.Clear(fg, bg)
+	writeText(1, 1, -1, "^B^cRoguelike^!", fg, bg)
+	writeText(1, 3, -1, "Press ^BRETURN^! to start a new game", fg, bg)
+	writeText(1, 4, -1, "Press ^BESC^! to quit", fg, bg)
+	termbox.Flush()
+
+	for {
+		evt := termbox.PollEvent()
+		if evt.Type != termbox.EventKey {
+			continue
+		}
+
+		switch evt.Key {
+		case termbox.KeyEnter:
+			return true
+		case termbox.KeyEsc:
+			return false
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package lib
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// directionNames are the names of the directions the player can face.
+var directionNames = []string{"north", "east", "south", "west"}
+
+// A Narrator describes the game in plain sentences, for players who can't
+// see the screen. It remembers what it's already pointed out, so that it
+// only mentions things when they first come into sight.
+type Narrator struct {
+	file *os.File
+
+	level   *Map
+	noticed map[interface{}]bool
+}
+
+// A pointOfInterest is something on the level worth telling the player
+// about.
+type pointOfInterest struct {
+	X, Y int
+	Name string
+
+	// key identifies the point of interest, so it's only pointed out once.
+	key interface{}
+}
+
+// narrator returns the game's narrator, setting it up the first time it's
+// needed.
+func (g *Game) narrator() *Narrator {
+	if g.Narrator != nil {
+		return g.Narrator
+	}
+
+	n := &Narrator{}
+
+	if Conf.NarrationFile != "" {
+		f, err := os.OpenFile(Conf.NarrationFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			log.Printf("couldn't open the narration file: %v", err)
+		} else {
+			n.file = f
+		}
+	}
+
+	g.Narrator = n
+	return n
+}
+
+// Narrate adds a line of narration to the message log, if narration is
+// turned on.
+func (g *Game) Narrate(format string, args ...interface{}) {
+	if !Conf.Narrate {
+		return
+	}
+
+	line := fmt.Sprintf(format, args...)
+	g.UI.Messages = append(g.UI.Messages, line)
+	g.narrator().write(line)
+}
+
+// write writes a line to the narration file, without any markup codes.
+func (n *Narrator) write(line string) {
+	if n.file != nil {
+		fmt.Fprintln(n.file, plainText(line))
+	}
+}
+
+// Close closes the narration file. It's safe to call on a nil narrator.
+func (n *Narrator) Close() {
+	if n != nil && n.file != nil {
+		n.file.Close()
+	}
+}
+
+// plainText strips the markup codes out of some text.
+func plainText(text string) string {
+	var b strings.Builder
+
+	for _, span := range parseMarkup(text, 0, 0) {
+		b.WriteString(span.Text)
+	}
+
+	return b.String()
+}
+
+// relativePlace describes where something dx, dy tiles away is, e.g. "3
+// north and 2 east".
+func relativePlace(dx, dy int) string {
+	parts := []string{}
+
+	if dy < 0 {
+		parts = append(parts, fmt.Sprintf("%d north", -dy))
+	} else if dy > 0 {
+		parts = append(parts, fmt.Sprintf("%d south", dy))
+	}
+
+	if dx > 0 {
+		parts = append(parts, fmt.Sprintf("%d east", dx))
+	} else if dx < 0 {
+		parts = append(parts, fmt.Sprintf("%d west", -dx))
+	}
+
+	if len(parts) == 0 {
+		return "right here"
+	}
+
+	return strings.Join(parts, " and ")
+}
+
+// tileNoun says what a tile is, in a few words.
+func tileNoun(t Tile) string {
+	switch t := t.(type) {
+	case *WallTile, *OutsideTile:
+		return "a wall"
+	case *BoxTile:
+		return "a box"
+	case *ChestTile:
+		switch {
+		case t.Open:
+			return "an open chest"
+		case t.Rare:
+			return "a gilded chest"
+		default:
+			return "a chest"
+		}
+	case *TrapdoorTile:
+		if t.Locked {
+			return "the locked trapdoor"
+		}
+
+		return "the trapdoor"
+	case *MerchantTile:
+		return "a merchant"
+	case *TrapTile:
+		switch {
+		case t.Hidden:
+			return tileNoun(&FloorTile{Items: t.Items})
+		case t.Disarmed:
+			return withArticle("disarmed " + trapNames[t.Kind])
+		default:
+			return withArticle(trapNames[t.Kind])
+		}
+	case *FloorTile:
+		if len(t.Items) > 0 {
+			return describeItems(t.Items)
+		}
+
+		return "floor"
+	default:
+		return "something"
+	}
+}
+
+// whatsAt says what the player would find at (x, y): whoever's standing
+// there, or else the tile.
+func (g *Game) whatsAt(x, y int) string {
+	if a := g.Level.ActorAt(x, y); a != nil {
+		return "the " + a.Name()
+	}
+
+	return tileNoun(g.Level.At(x, y))
+}
+
+// pointsOfInterest returns everything interesting the player can see,
+// nearest first.
+func (g *Game) pointsOfInterest() []pointOfInterest {
+	var (
+		p      = g.Player
+		m      = g.Level
+		r      = p.viewRadius()
+		points = []pointOfInterest{}
+	)
+
+	for _, a := range m.Actors {
+		if m.CanSee(p.X, p.Y, a.X, a.Y, r) {
+			points = append(points, pointOfInterest{a.X, a.Y, "the " + a.Name(), a})
+		}
+	}
+
+	for y := p.Y - r; y <= p.Y+r; y++ {
+		for x := p.X - r; x <= p.X+r; x++ {
+			if (x == p.X && y == p.Y) || !m.CanSee(p.X, p.Y, x, y, r) {
+				continue
+			}
+
+			switch t := m.At(x, y).(type) {
+			case *ChestTile:
+				if t.Open {
+					continue
+				}
+			case *TrapTile:
+				if t.Hidden && len(t.Items) == 0 || !t.Hidden && t.Disarmed {
+					continue
+				}
+			case *FloorTile:
+				if len(t.Items) == 0 {
+					continue
+				}
+			case *TrapdoorTile, *MerchantTile, *BoxTile:
+			default:
+				continue
+			}
+
+			name := tileNoun(m.At(x, y))
+			points = append(points, pointOfInterest{x, y, name, fmt.Sprint(image.Pt(x, y), name)})
+		}
+	}
+
+	sort.SliceStable(points, func(i, j int) bool {
+		di := abs(points[i].X-p.X) + abs(points[i].Y-p.Y)
+		dj := abs(points[j].X-p.X) + abs(points[j].Y-p.Y)
+		return di < dj
+	})
+
+	return points
+}
+
+// narrateSurroundings points out anything interesting which has come into
+// sight since it was last called.
+func (g *Game) narrateSurroundings() {
+	if !Conf.Narrate {
+		return
+	}
+
+	n := g.narrator()
+	if n.level != g.Level {
+		n.level = g.Level
+		n.noticed = make(map[interface{}]bool)
+	}
+
+	seen := []string{}
+
+	for _, poi := range g.pointsOfInterest() {
+		if n.noticed[poi.key] {
+			continue
+		}
+
+		n.noticed[poi.key] = true
+		seen = append(seen, poi.Name+", "+relativePlace(poi.X-g.Player.X, poi.Y-g.Player.Y))
+	}
+
+	if len(seen) > 0 {
+		g.Narrate("You see %s.", strings.Join(seen, "; "))
+	}
+}
+
+// DescribeSurroundings tells the player where they are, what's around them
+// and everything interesting they can see.
+func (g *Game) DescribeSurroundings() {
+	var (
+		p     = g.Player
+		dirs  = [][2]int{{0, -1}, {1, 0}, {0, 1}, {-1, 0}}
+		sides = make([]string, len(dirs))
+	)
+
+	for i, d := range dirs {
+		sides[i] = directionNames[i] + ": " + g.whatsAt(p.X+d[0], p.Y+d[1])
+	}
+
+	text := fmt.Sprintf("You're on level %d at %d, %d, facing %s. %s.",
+		g.Level.Depth, p.X, p.Y, directionNames[p.Direction], strings.Join(sides, ", "))
+
+	if points := g.pointsOfInterest(); len(points) > 0 {
+		seen := make([]string, len(points))
+		for i, poi := range points {
+			seen[i] = poi.Name + ", " + relativePlace(poi.X-p.X, poi.Y-p.Y)
+		}
+
+		text += " You can see " + strings.Join(seen, "; ") + "."
+	} else {
+		text += " There's nothing interesting in sight."
+	}
+
+	g.UI.SetMinibuf(text, time.Second*8)
+}
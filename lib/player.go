@@ -1,10 +1,10 @@
 package lib
 
 import (
+	"fmt"
+	"math"
 	"math/rand"
 	"time"
-
-	termbox "github.com/nsf/termbox-go"
 )
 
 // A Player is the user's player, and stores things such as position.
@@ -13,78 +13,195 @@ type Player struct {
 
 	Money      int
 	Health     int
+	MaxHealth  int
 	Experience int
+	Level      int
 	Attack     int
 	Defense    int
 	Magic      int
 
+	Effects Effects
+
+	// Satiety is how full the player is. It goes down by one every turn,
+	// and the player starts starving when it reaches zero.
+	Satiety int
+
+	// Inventory stores every item the player is carrying, and Equipment
+	// stores the ones they have equipped, keyed by slot.
+	Inventory []Item
+	Equipment map[string]Item
+
+	// CauseOfDeath says what killed the player, once they're dead.
+	CauseOfDeath string
+
+	// journey is where the player is travelling to, if they've clicked on
+	// a tile far away.
+	journey *journey
+
 	Game      *Game
 	Direction int // 0: top, 1: right, 2: bottom, 3: left
 }
 
 // NewPlayer creates a new player, choosing random valid coordinates.
 func NewPlayer(g *Game) *Player {
-	for {
-		var (
-			x = rand.Intn(g.Level.Width())
-			y = rand.Intn(g.Level.Height())
-		)
-
-		if g.Level.At(x, y).Type() == TileFloor {
-			return &Player{
-				X:          x,
-				Y:          y,
-				Money:      0,
-				Health:     100,
-				Experience: 0,
-				Attack:     1,
-				Defense:    1,
-				Magic:      1,
-				Game:       g,
-			}
-		}
+	x, y := g.Level.SpawnPoint()
+
+	p := &Player{
+		X:          x,
+		Y:          y,
+		Money:      0,
+		Health:     100,
+		MaxHealth:  100,
+		Experience: 0,
+		Level:      1,
+		Attack:     1,
+		Defense:    1,
+		Magic:      1,
+		Satiety:    Conf.MaxSatiety,
+		Equipment:  make(map[string]Item),
+		Game:       g,
 	}
+
+	p.giveStartingItems()
+	p.look()
+
+	return p
 }
 
 // Move translates the player (dx, dy) units, but only if it will still
-// be in a valid position.
+// be in a valid position. A confused player might stumble in the wrong
+// direction.
 func (p *Player) Move(dx, dy int) {
+	if p.Effects.Has(EffectConfusion) && rand.Float64() < 0.5 {
+		dirs := [][]int{{0, -1}, {1, 0}, {0, 1}, {-1, 0}}
+		dir := dirs[rand.Intn(len(dirs))]
+		dx, dy = dir[0], dir[1]
+	}
+
 	nx, ny := p.X+dx, p.Y+dy
 	tile := p.Game.Level.At(nx, ny)
 
+	if a := p.Game.Level.ActorAt(nx, ny); a != nil {
+		p.AttackActor(a)
+		p.Game.EndTurn()
+		return
+	}
+
 	if !tile.Passable() {
+		p.Game.Narrate("There's %s in the way to the %s.", tileNoun(tile), directionNames[directionOf(dx, dy)])
+		return
+	}
+
+	p.walk(dx, dy)
+	p.Game.EndTurn()
+}
+
+// walk moves the player one tile, without checking whether they can. It's
+// up to the caller to end the turn.
+func (p *Player) walk(dx, dy int) {
+	p.X += dx
+	p.Y += dy
+	p.Game.Narrate("You walk %s.", directionNames[directionOf(dx, dy)])
+
+	p.Game.Level.At(p.X, p.Y).OnWalk(p.Game)
+	p.look()
+	p.seeItems()
+}
+
+// Turn turns the player to face a direction, without moving.
+func (p *Player) Turn(dir int) {
+	p.Direction = dir
+
+	x, y := p.GetFacing()
+	p.Game.Narrate("You turn to face %s, towards %s.", directionNames[dir], p.Game.whatsAt(x, y))
+}
+
+// Name returns the name of the player, for use in messages.
+func (p *Player) Name() string {
+	return "you"
+}
+
+// Damage hurts the player, never taking their health below zero. If it
+// kills them, the cause is remembered for the morgue file.
+func (p *Player) Damage(amount int, cause string) {
+	if p.Dead() {
 		return
 	}
 
-	tile.OnWalk(p.Game)
+	p.Health -= amount
 
-	p.X = nx
-	p.Y = ny
+	if amount > 0 {
+		p.Game.PlayAnimation(flashAnimation(p.X, p.Y))
+	}
+
+	if p.Health <= 0 {
+		p.Health = 0
+		p.CauseOfDeath = cause
+	}
+}
+
+// Dead checks whether the player has been killed.
+func (p *Player) Dead() bool {
+	return p.Health <= 0
+}
+
+// Heal heals the player, up to their maximum health.
+func (p *Player) Heal(amount int) {
+	p.Health += amount
+
+	if max := p.Effective().Health; p.Health > max {
+		p.Health = max
+	}
+}
+
+// Status returns the status effects active on the player.
+func (p *Player) Status() *Effects {
+	return &p.Effects
 }
 
 // Render renders a Player to the terminal, assuming the top-left of the
 // map is at (x, y)
 func (p *Player) Render(x, y int) {
-	ch := []rune{
-		'▲',
-		'▶',
-		'▼',
-		'◀',
-	}[p.Direction]
+	role := CurrentTheme.Role("player")
+
+	// The player's glyph has a character for each direction they can face,
+	// in the order up, right, down, left.
+	if glyphs := []rune(role.Glyph); len(glyphs) == 4 {
+		role.Glyph = string(glyphs[p.Direction]) + " "
+	}
 
-	termbox.SetCell(x+p.X*2, y+p.Y, ch, termbox.ColorCyan, termbox.ColorDefault)
-	termbox.SetCell(x+p.X*2+1, y+p.Y, ' ', termbox.ColorCyan, termbox.ColorDefault)
+	role.Render(x+p.X*2, y+p.Y)
 }
 
-// Interact makes the player interact with whatever tile is in front
+// Interact makes the player interact with whatever is in front of them,
+// attacking it if it's an actor.
 func (p *Player) Interact() {
 	x, y := p.GetFacing()
-	p.Game.Level.At(x, y).OnInteract(x, y, p.Game)
+
+	if a := p.Game.Level.ActorAt(x, y); a != nil {
+		p.AttackActor(a)
+	} else {
+		p.Game.Level.At(x, y).OnInteract(x, y, p.Game)
+	}
+
+	p.Game.EndTurn()
 }
 
-// Inspect inspects the tile in front of the player
+// Inspect inspects the tile in front of the player, which will uncover
+// it if it's a hidden trap.
 func (p *Player) Inspect() {
-	tile := p.Game.Level.At(p.GetFacing())
+	x, y := p.GetFacing()
+	tile := p.Game.Level.At(x, y)
+
+	if a := p.Game.Level.ActorAt(x, y); a != nil {
+		p.Game.UI.SetMinibuf(fmt.Sprintf("The %s. It has %d/%d health.", a.Name(), a.Health, a.MaxHealth), time.Second*4)
+		return
+	}
+
+	if trap, ok := tile.(*TrapTile); ok {
+		trap.Hidden = false
+	}
+
 	p.Game.UI.SetMinibuf(tile.Description(), time.Second*4)
 }
 
@@ -104,41 +221,128 @@ func (p *Player) GetFacing() (x, y int) {
 	}
 }
 
-func levelChangeConfirm(g *Game) bool {
-	stop := make(chan bool, 1)
+// checkExperience makes sure each level needs more experience than the
+// last, since otherwise the player would level up forever.
+func checkExperience() error {
+	if Conf.LevelXPBase <= 0 {
+		return fmt.Errorf("level-xp-base must be more than 0")
+	}
+
+	if Conf.LevelXPGrowth < 1 {
+		return fmt.Errorf("level-xp-growth must be at least 1")
+	}
+
+	return nil
+}
+
+// xpForLevel returns the total amount of experience needed to reach the
+// given level.
+func xpForLevel(level int) int {
+	total := 0
+
+	for l := 1; l < level; l++ {
+		total += int(float64(Conf.LevelXPBase) * math.Pow(Conf.LevelXPGrowth, float64(l-1)))
+	}
+
+	return total
+}
+
+// LevelProgress returns how much experience the player has gained since
+// their last level up, and how much they need in total to reach the next
+// level.
+func (p *Player) LevelProgress() (have, need int) {
+	var (
+		current = xpForLevel(p.Level)
+		next    = xpForLevel(p.Level + 1)
+	)
+
+	return p.Experience - current, next - current
+}
+
+// GainExperience gives the player some experience, levelling them up as
+// many times as the new total allows.
+func (p *Player) GainExperience(amount int) {
+	p.Experience += amount
+
+	points := 0
+
+	for p.Experience >= xpForLevel(p.Level+1) {
+		p.Level++
+		points += Conf.LevelUpPoints
+	}
+
+	if points > 0 {
+		levelUp(p, points, 0)
+	}
+}
+
+// levelUp asks the player which stat to put one of their points into, and
+// keeps asking until they've spent them all. selected is the stat which is
+// chosen to begin with.
+func levelUp(p *Player, points, selected int) {
+	options := []string{
+		fmt.Sprintf("attack      %d", p.Attack),
+		fmt.Sprintf("defense     %d", p.Defense),
+		fmt.Sprintf("magic       %d", p.Magic),
+		fmt.Sprintf("max health  %d (+%d)", p.MaxHealth, Conf.LevelUpHealth),
+	}
 
-	delayText(
-		1, 0, time.Millisecond*10,
-		`
-Entering level ^B%d^!...
+	p.Game.OpenDialog(&MenuDialog{
+		Title:    fmt.Sprintf("Level %d! %d points left", p.Level, points),
+		Options:  options,
+		Selected: selected,
+		OnSelect: func(i int) {
+			// The points have to be spent, so ESC just asks again.
+			if i < 0 {
+				levelUp(p, points, selected)
+				return
+			}
+
+			switch i {
+			case 0:
+				p.Attack++
+			case 1:
+				p.Defense++
+			case 2:
+				p.Magic++
+			case 3:
+				p.MaxHealth += Conf.LevelUpHealth
+				p.Health += Conf.LevelUpHealth
+			}
+
+			if points > 1 {
+				levelUp(p, points-1, i)
+			}
+		},
+	})
+}
+
+// levelChangeConfirm shows the player how they're doing and asks if they
+// want to go down to the next level, calling enter if they do.
+func levelChangeConfirm(g *Game, next *Map, enter func()) {
+	have, need := g.Player.LevelProgress()
+	stats := g.Player.Effective()
 
-^r health:  %d
+	g.OpenDialog(&ConfirmDialog{
+		Title: fmt.Sprintf("Entering level %d", next.Depth),
+		Text: fmt.Sprintf(`^b level:   %d (%d/%d xp)
+^r health:  %d/%d
 ^g money:   %d
 ^y xp:      %d
 ^c attack:  %d
 ^w defense: %d
-^m magic:   %d ^!
-
-Press ^BRETURN^! to enter the next level
-Press ^BESC^! to stay on the current level`,
-		termbox.ColorDefault, termbox.ColorDefault, stop,
-		g.Level.Depth, g.Player.Health,
-		g.Player.Money, g.Player.Experience,
-		g.Player.Attack, g.Player.Defense,
-		g.Player.Magic)
-
-	for {
-		switch evt := termbox.PollEvent(); evt.Type {
-		case termbox.EventKey:
-			if evt.Key == termbox.KeyEnter {
-				stop <- true
-				return true
+^m magic:   %d ^!`,
+			g.Player.Level, have, need,
+			g.Player.Health, stats.Health,
+			g.Player.Money, g.Player.Experience,
+			stats.Attack, stats.Defense,
+			stats.Magic),
+		Yes: "enter the next level",
+		No:  "stay on the current level",
+		OnResult: func(yes bool) {
+			if yes {
+				enter()
 			}
-
-			if evt.Key == termbox.KeyEsc {
-				stop <- true
-				return false
-			}
-		}
-	}
+		},
+	})
 }
@@ -0,0 +1,138 @@
+package lib
+
+import (
+	"math"
+
+	"github.com/nsf/termbox-go"
+)
+
+// A Tier is a human-readable band of item quality, e.g. "fine".
+type Tier struct {
+	Name       string
+	MinQuality int
+	Colour     termbox.Attribute
+}
+
+// tiers are the quality tiers, from worst to best.
+var tiers = []Tier{
+	{Name: "junk", MinQuality: 0, Colour: 0x09},
+	{Name: "common", MinQuality: 10, Colour: termbox.ColorWhite},
+	{Name: "fine", MinQuality: 25, Colour: termbox.ColorGreen},
+	{Name: "superior", MinQuality: 45, Colour: termbox.ColorBlue},
+	{Name: "masterwork", MinQuality: 75, Colour: termbox.ColorMagenta},
+	{Name: "mythic", MinQuality: 120, Colour: termbox.ColorYellow | termbox.AttrBold},
+}
+
+// calculateQuality works out the quality of an item with the given
+// attributes. The quality of the kind, type and modifiers are added together
+// and then the rarity's quality is added on top, but an item's quality can
+// never be less than one, however many bad modifiers it has.
+func calculateQuality(t ItemType, r ItemRarity, mods []ItemModifier) int {
+	q := t.Kind.Quality + t.Quality
+
+	for _, mod := range mods {
+		q += mod.Quality
+	}
+
+	q += r.Quality
+
+	if q < 1 {
+		return 1
+	}
+
+	return q
+}
+
+// TierOf returns the quality tier that an item falls into.
+func TierOf(i Item) Tier {
+	q := i.Quality()
+	tier := tiers[0]
+
+	for _, t := range tiers {
+		if q >= t.MinQuality {
+			tier = t
+		}
+	}
+
+	return tier
+}
+
+// BuyPrice returns how much an item costs to buy from a merchant. Prices
+// grow faster than quality does, so that the very best items are properly
+// expensive.
+func BuyPrice(i Item) int {
+	return int(math.Ceil(Conf.PricePerQuality * math.Pow(float64(i.Quality()), Conf.PriceExponent)))
+}
+
+// SellPrice returns how much a merchant will pay for an item.
+func SellPrice(i Item) int {
+	return int(float64(BuyPrice(i)) * Conf.SellMarkdown)
+}
+
+// renderTooltip renders a box of information about an item at (x, y),
+// wrapping at the given width, and returns the y coordinate after it. k is
+// what the player knows about the item.
+func renderTooltip(k *Knowledge, x, y, width int, i Item) int {
+	var (
+		fg, bg = termbox.ColorDefault, termbox.ColorDefault
+		tier   = TierOf(i)
+		stats  = i.Stats()
+	)
+
+	writeText(x, y, -1, "%s", i.Rarity().Fg, i.Rarity().Bg, i.Name())
+	writeText(x, y+1, -1, "%s %s", CurrentTheme.Fg("dim"), bg, i.Rarity().Name, i.Type().Kind.Name)
+
+	if !k.Identified(i) {
+		writeText(x, y+3, -1, "^yunidentified^!", fg, bg)
+		y += 5
+
+		if k.typeName(i.Type()) == i.Type().Name && i.Type().Description != "" {
+			writeText(x, y, x+width-1, "%s", fg, bg, i.Type().Description)
+			_, h := measureText(width, "%s", i.Type().Description)
+			y += h
+		} else {
+			writeText(x, y, x+width-1, "You don't know what this does yet.", CurrentTheme.Fg("dim"), bg)
+			y++
+		}
+
+		return y
+	}
+
+	writeText(x, y+3, -1, "quality: %d", fg, bg, i.Quality())
+	writeText(x, y+4, -1, "   tier:", fg, bg)
+	writeText(x+9, y+4, -1, "%s", tier.Colour, bg, tier.Name)
+	writeText(x, y+5, -1, "  value: ^g£%d^! (sells for ^g£%d^!)", fg, bg, BuyPrice(i), SellPrice(i))
+
+	y += 7
+
+	for _, s := range []struct {
+		name  string
+		value int
+	}{
+		{"attack", stats.Attack},
+		{"defense", stats.Defense},
+		{"magic", stats.Magic},
+		{"health", stats.Health},
+		{"speed", stats.Speed},
+	} {
+		switch {
+		case s.value > 0:
+			writeText(x, y, -1, "^g+%d^! %s", fg, bg, s.value, s.name)
+		case s.value < 0:
+			writeText(x, y, -1, "^r%d^! %s", fg, bg, s.value, s.name)
+		default:
+			continue
+		}
+
+		y++
+	}
+
+	if i.Type().Description != "" {
+		y++
+		writeText(x, y, x+width-1, "%s", fg, bg, i.Type().Description)
+		_, h := measureText(width, "%s", i.Type().Description)
+		y += h
+	}
+
+	return y
+}
@@ -0,0 +1,52 @@
+package lib
+
+// Base returns the player's own stats, before their equipment and status
+// effects are taken into account.
+func (p *Player) Base() Stats {
+	return Stats{
+		Attack:  p.Attack,
+		Defense: p.Defense,
+		Magic:   p.Magic,
+		Health:  p.MaxHealth,
+	}
+}
+
+// Effective returns the player's stats once the bonuses from everything
+// they have equipped and the status effects they're under are added on.
+// This is what should be used whenever a stat actually matters, e.g. in
+// combat. The health stat is the player's effective max health.
+func (p *Player) Effective() Stats {
+	stats := p.Base()
+
+	for _, slot := range slots {
+		if item := p.Equipment[slot]; item != nil {
+			stats = stats.Add(item.Stats())
+		}
+	}
+
+	for _, eff := range p.Effects.Active {
+		stats = stats.Add(effectInfos[eff.Kind].Stats)
+	}
+
+	if p.Hunger() >= HungerWeak {
+		stats.Attack -= stats.Attack / 2
+	}
+
+	stats.Attack = maxInt(stats.Attack, 0)
+	stats.Defense = maxInt(stats.Defense, 0)
+	stats.Magic = maxInt(stats.Magic, 0)
+	stats.Health = maxInt(stats.Health, 1)
+
+	return stats
+}
+
+// actionCost returns how much of a turn an action takes at the given
+// speed. Every point of speed is worth a quarter of a turn, so at +4 the
+// player gets two actions a turn, and at -4 each action takes two turns.
+func actionCost(speed int) float64 {
+	if speed >= 0 {
+		return 1 / (1 + 0.25*float64(speed))
+	}
+
+	return 1 - 0.25*float64(speed)
+}
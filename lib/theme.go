@@ -0,0 +1,289 @@
+package lib
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nsf/termbox-go"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// CurrentTheme is the theme everything is drawn with.
+var CurrentTheme *Theme
+
+// palette16 is set when the terminal can only show 16 colours, so every
+// colour has to be converted before it's drawn.
+var palette16 bool
+
+// maxThemeDepth is how many themes can extend each other in a chain, which
+// stops a theme which extends itself from looping forever.
+const maxThemeDepth = 8
+
+type (
+	// A Theme says how each role, such as "wall" or "merchant", is drawn.
+	Theme struct {
+		Name  string
+		Roles map[string]Role
+	}
+
+	// A Role is the glyph and colours something is drawn with. Fg16 and Bg16
+	// are used instead of Fg and Bg when the terminal only has 16 colours.
+	Role struct {
+		Glyph      string
+		Fg, Bg     termbox.Attribute
+		Fg16, Bg16 termbox.Attribute
+	}
+
+	// themeFile is the layout of a theme file.
+	themeFile struct {
+		Name    string `yaml:"name"`
+		Extends string `yaml:"extends"`
+
+		Roles map[string]struct {
+			Glyph *string `yaml:"glyph"`
+			Fg    *string `yaml:"fg"`
+			Bg    *string `yaml:"bg"`
+			Fg16  *string `yaml:"fg16"`
+			Bg16  *string `yaml:"bg16"`
+		} `yaml:"roles"`
+	}
+)
+
+// LoadTheme loads the named theme from a directory of theme files. A theme
+// can extend another one, in which case it only needs to give the parts of
+// the roles which are different.
+func LoadTheme(dir, name string) (*Theme, error) {
+	return loadTheme(dir, name, 0)
+}
+
+func loadTheme(dir, name string, depth int) (*Theme, error) {
+	if depth > maxThemeDepth {
+		return nil, fmt.Errorf("theme %s: too many themes extending each other", name)
+	}
+
+	file, err := os.Open(filepath.Join(dir, name+".yaml"))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	bytes, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw themeFile
+	if err = yaml.Unmarshal(bytes, &raw); err != nil {
+		return nil, err
+	}
+
+	t := &Theme{
+		Name:  raw.Name,
+		Roles: make(map[string]Role),
+	}
+
+	if raw.Extends != "" {
+		base, err := loadTheme(dir, raw.Extends, depth+1)
+		if err != nil {
+			return nil, err
+		}
+
+		for role, r := range base.Roles {
+			t.Roles[role] = r
+		}
+	}
+
+	for role, r := range raw.Roles {
+		out := t.Roles[role]
+
+		if r.Glyph != nil {
+			out.Glyph = *r.Glyph
+		}
+
+		for _, c := range []struct {
+			dst *termbox.Attribute
+			src *string
+		}{{&out.Fg, r.Fg}, {&out.Bg, r.Bg}, {&out.Fg16, r.Fg16}, {&out.Bg16, r.Bg16}} {
+			if c.src == nil {
+				continue
+			}
+
+			attr, err := parseColour(*c.src)
+			if err != nil {
+				return nil, fmt.Errorf("theme %s: role %s: %v", name, role, err)
+			}
+
+			*c.dst = attr
+		}
+
+		// Unless the theme says otherwise, the 16 colour versions are worked
+		// out from the 256 colour ones.
+		if r.Fg16 == nil && r.Fg != nil {
+			out.Fg16 = to16(out.Fg, true)
+		}
+
+		if r.Bg16 == nil && r.Bg != nil {
+			out.Bg16 = to16(out.Bg, false)
+		}
+
+		t.Roles[role] = out
+	}
+
+	return t, nil
+}
+
+// Role returns how the named role is drawn. Roles which the theme doesn't
+// mention are drawn as question marks, so they're easy to spot.
+func (t *Theme) Role(name string) Role {
+	if r, ok := t.Roles[name]; ok {
+		return r
+	}
+
+	return Role{Glyph: "??"}
+}
+
+// Fg returns the foreground colour of the named role.
+func (t *Theme) Fg(name string) termbox.Attribute {
+	fg, _ := t.Role(name).colours()
+	return fg
+}
+
+// colours returns the colours a role should be drawn in on this terminal.
+func (r Role) colours() (termbox.Attribute, termbox.Attribute) {
+	if palette16 {
+		return r.Fg16, r.Bg16
+	}
+
+	return r.Fg, r.Bg
+}
+
+// Render draws a role's glyph at (x, y).
+func (r Role) Render(x, y int) {
+	fg, bg := r.colours()
+	drawGlyph(x, y, r.Glyph, fg, bg)
+}
+
+// SetOutputMode sets termbox's output mode from the colours setting in the
+// config, which can be "256", "16", or "auto" to guess from the terminal.
+func SetOutputMode() {
+	switch Conf.Colours {
+	case "256":
+		palette16 = false
+	case "16":
+		palette16 = true
+	default:
+		palette16 = !supports256()
+	}
+
+	if palette16 {
+		termbox.SetOutputMode(termbox.OutputNormal)
+	} else {
+		termbox.SetOutputMode(termbox.Output256)
+	}
+}
+
+// supports256 guesses whether the terminal can show 256 colours, from its
+// environment variables.
+func supports256() bool {
+	term := os.Getenv("TERM")
+
+	return strings.Contains(term, "256") ||
+		strings.Contains(term, "kitty") ||
+		strings.Contains(term, "direct") ||
+		os.Getenv("COLORTERM") != ""
+}
+
+// colourMask is the part of an attribute which is the colour, rather than
+// things like bold and underline.
+const colourMask termbox.Attribute = 0x1ff
+
+// to16 converts a colour from the 256 colour palette to the closest of the
+// 8 basic colours. Bright foreground colours are made bold, since that's
+// how most terminals show them.
+func to16(a termbox.Attribute, fg bool) termbox.Attribute {
+	var (
+		c     = int(a & colourMask)
+		attrs = a &^ colourMask
+		base  int
+	)
+
+	switch {
+	case c <= 8:
+		return a
+
+	case c <= 16:
+		base = c - 8
+		if fg {
+			attrs |= termbox.AttrBold
+		}
+
+	case c <= 232:
+		n := c - 17
+		r, g, b := n/36, (n/6)%6, n%6
+
+		for i, level := range []int{r, g, b} {
+			if level >= 3 {
+				base |= 1 << uint(i)
+			}
+		}
+
+		base++
+
+		if fg && (r >= 4 || g >= 4 || b >= 4) && base != 1 {
+			attrs |= termbox.AttrBold
+		}
+
+	default:
+		switch level := c - 233; {
+		case level < 8:
+			base = 1
+		case level < 16:
+			base = 1
+			if fg {
+				attrs |= termbox.AttrBold
+			}
+		default:
+			base = 8
+		}
+	}
+
+	return termbox.Attribute(base) | attrs
+}
+
+// outputColour converts a colour so that it can be drawn on the terminal.
+func outputColour(a termbox.Attribute, fg bool) termbox.Attribute {
+	if palette16 {
+		return to16(a, fg)
+	}
+
+	return a
+}
+
+// loadCurrentTheme loads the theme named in the config into CurrentTheme.
+func loadCurrentTheme() error {
+	t, err := LoadTheme(Conf.ThemeDir, Conf.Theme)
+	if err != nil {
+		return err
+	}
+
+	// The ASCII glyphs replace the theme's glyphs, but not its colours.
+	if Conf.ASCII {
+		ascii, err := LoadTheme(Conf.ThemeDir, "ascii")
+		if err != nil {
+			return err
+		}
+
+		for name, r := range ascii.Roles {
+			role := t.Roles[name]
+			role.Glyph = r.Glyph
+			t.Roles[name] = role
+		}
+	}
+
+	CurrentTheme = t
+
+	return nil
+}
@@ -3,8 +3,6 @@ package lib
 import (
 	"math/rand"
 	"time"
-
-	"github.com/nsf/termbox-go"
 )
 
 // The set of tile types
@@ -18,6 +16,7 @@ const (
 	TileChest
 	TileTrapdoor
 	TileMerchant
+	TileTrap
 )
 
 // A Tile is a single block in the world
@@ -38,9 +37,10 @@ func (t *tileDefaults) OnWalk(g *Game)               {}
 func (t *tileDefaults) OnInteract(x, y int, g *Game) {}
 
 type (
-	// A FloorTile is a walkable tile
+	// A FloorTile is a walkable tile, which items can be dropped on
 	FloorTile struct {
 		*tileDefaults
+		Items []Item
 	}
 
 	// A WallTile is a tile which the player cannot move through
@@ -57,24 +57,54 @@ type (
 	// by shooting it
 	BoxTile struct {
 		*tileDefaults
+
+		// Under is the tile the box has been pushed onto, which comes back
+		// when it's pushed off again or smashed. It's usually the floor, but
+		// it might be a hidden trap.
+		Under Tile
 	}
 
 	// A ChestTile is a tile which, when touched, will open and give the player
-	// some money and xp
+	// some money and xp. Rare chests give much more.
 	ChestTile struct {
 		*tileDefaults
 		Open bool
+		Rare bool
 	}
 
 	// A TrapdoorTile is a tile which, when touched, transports the player to the
-	// next level
+	// next level. A locked trapdoor can't be used until the level's boss is dead.
 	TrapdoorTile struct {
 		*tileDefaults
+		Locked bool
 	}
 
 	// A MerchantTile is a tile which allows the player to buy and sell items
 	MerchantTile struct {
 		*tileDefaults
+
+		// Stock is the merchant's items for sale, which is generated the first
+		// time the player trades with them. Discounts stores how much cheaper
+		// the player has haggled each item down by.
+		Stock     []Item
+		Discounts map[Item]float64
+
+		// Reputation is how much the merchant likes the player, which changes
+		// their prices.
+		Reputation int
+	}
+
+	// A TrapTile is a tile which looks just like the floor until it's been
+	// discovered, and does something nasty when it's walked on
+	TrapTile struct {
+		*tileDefaults
+		Kind     int
+		Hidden   bool
+		Disarmed bool
+
+		// Items can be dropped on a trap, the same as on the floor, so that
+		// where they land doesn't give away a hidden one.
+		Items []Item
 	}
 )
 
@@ -82,41 +112,72 @@ type (
 
 // Render renders a tile to the terminal
 func (f *FloorTile) Render(x, y int) {
-	writeText(x, y, -1, "  ", termbox.ColorDefault, termbox.ColorDefault)
+	renderFloor(x, y, f.Items)
+}
+
+// renderFloor draws a floor tile with some items lying on it.
+func renderFloor(x, y int, items []Item) {
+	switch len(items) {
+	case 0:
+		CurrentTheme.Role("floor").Render(x, y)
+	case 1:
+		r := items[0].Rarity()
+		drawGlyph(x, y, items[0].Type().Glyph+" ", r.Fg, r.Bg)
+	default:
+		CurrentTheme.Role("item-pile").Render(x, y)
+	}
 }
 
 // Render renders a tile to the terminal
 func (f *WallTile) Render(x, y int) {
-	writeText(x, y, -1, "  ", termbox.ColorDefault, 0x10)
+	CurrentTheme.Role("wall").Render(x, y)
 }
 
 // Render renders a tile to the terminal
 func (f *OutsideTile) Render(x, y int) {
-	writeText(x, y, -1, "  ", termbox.ColorDefault, termbox.ColorWhite)
+	CurrentTheme.Role("outside").Render(x, y)
 }
 
 // Render renders a tile to the terminal
 func (f *BoxTile) Render(x, y int) {
-	writeText(x, y, -1, "▨ ", termbox.ColorYellow|termbox.AttrBold, termbox.ColorDefault)
+	CurrentTheme.Role("box").Render(x, y)
 }
 
 // Render renders a tile to the terminal
 func (f *ChestTile) Render(x, y int) {
 	if f.Open {
-		writeText(x, y, -1, "$ ", termbox.ColorRed|termbox.AttrBold, termbox.ColorDefault)
+		CurrentTheme.Role("chest-open").Render(x, y)
+	} else if f.Rare {
+		CurrentTheme.Role("chest-rare").Render(x, y)
 	} else {
-		writeText(x, y, -1, "$ ", termbox.ColorGreen|termbox.AttrBold, termbox.ColorDefault)
+		CurrentTheme.Role("chest").Render(x, y)
 	}
 }
 
 // Render renders a tile to the terminal
 func (f *TrapdoorTile) Render(x, y int) {
-	writeText(x, y, -1, "[]", 0x0d, termbox.ColorDefault)
+	if f.Locked {
+		CurrentTheme.Role("trapdoor-locked").Render(x, y)
+	} else {
+		CurrentTheme.Role("trapdoor").Render(x, y)
+	}
 }
 
 // Render renders a tile to the terminal
 func (f *MerchantTile) Render(x, y int) {
-	writeText(x, y, -1, "M ", termbox.ColorMagenta|termbox.AttrBold, termbox.ColorDefault)
+	CurrentTheme.Role("merchant").Render(x, y)
+}
+
+// Render renders a tile to the terminal
+func (f *TrapTile) Render(x, y int) {
+	switch {
+	case f.Hidden:
+		renderFloor(x, y, f.Items)
+	case f.Disarmed:
+		CurrentTheme.Role("trap-disarmed").Render(x, y)
+	default:
+		CurrentTheme.Role("trap").Render(x, y)
+	}
 }
 
 // Type() definitions
@@ -156,10 +217,19 @@ func (f *MerchantTile) Type() int {
 	return TileMerchant
 }
 
+// Type gets the type of a tile
+func (f *TrapTile) Type() int {
+	return TileTrap
+}
+
 // Description() definitions
 
 // Description returns a human-readable description of a tile
 func (f *FloorTile) Description() string {
+	if len(f.Items) > 0 {
+		return "There's " + describeItems(f.Items) + " lying here."
+	}
+
 	return "Stop looking at the floor, do something interesting"
 }
 
@@ -175,7 +245,7 @@ func (f *OutsideTile) Description() string {
 
 // Description returns a human-readable description of a tile
 func (f *BoxTile) Description() string {
-	return "Shoot this, maybe it will drop something cool. Or maybe not, who knows?"
+	return "Push it (press S), or smash it against a wall. Maybe it will drop something cool. Or maybe not, who knows?"
 }
 
 // Description returns a human-readable description of a tile
@@ -184,17 +254,37 @@ func (f *ChestTile) Description() string {
 		return "Looks like you've already opened this. Too bad :("
 	}
 
+	if f.Rare {
+		return "A gilded chest, left behind by whatever guarded this place. It must be full of treasure!"
+	}
+
 	return "Lucky you! You found a chest. Interact with it (press S) to get some money."
 }
 
 // Description returns a human-readable description of a tile
 func (f *TrapdoorTile) Description() string {
+	if f.Locked {
+		return "A heavy trapdoor, locked shut. You'll need to defeat the boss of this level to open it."
+	}
+
 	return "Walk on this to go to the next level. Make sure you've done everything you want to here!"
 }
 
 // Description returns a human-readable description of a tile
 func (f *MerchantTile) Description() string {
-	return "wNaT tO tRadDE sOmE StuFF?!11?1!!"
+	return "wNaT tO tRadDE sOmE StuFF?!11?1!! (press S to trade)"
+}
+
+// Description returns a human-readable description of a tile
+func (f *TrapTile) Description() string {
+	switch {
+	case f.Hidden:
+		return (&FloorTile{Items: f.Items}).Description()
+	case f.Disarmed:
+		return "A " + trapNames[f.Kind] + ". You've disarmed it, so it's harmless now."
+	default:
+		return "A " + trapNames[f.Kind] + "! Interact with it (press S) to try to disarm it."
+	}
 }
 
 // Passable() definitions
@@ -215,19 +305,33 @@ func (f *MerchantTile) Passable() bool { return false }
 
 // OnWalk is a callback which is fired when the tile is stepped on by the player
 func (f *TrapdoorTile) OnWalk(g *Game) {
-	prev := g.Level
-	g.Level = MakeMap(g.Level.Depth + 1)
+	if f.Locked {
+		bossWarning(g)
+		return
+	}
 
-	if levelChangeConfirm(g) {
-		g.Player.Game.Level = g.Level
+	next := MakeMap(g.Level.Depth + 1)
+
+	levelChangeConfirm(g, next, func() {
+		g.PlayAnimation(fallAnimation(g.Player.X, g.Player.Y, func() {
+			g.Level = next
+			g.Player.X, g.Player.Y = g.Level.SpawnPoint()
+			g.Player.look()
+			g.Narrate("You climb down through the trapdoor to level %d.", g.Level.Depth)
+			g.Player.seeItems()
+			g.narrateSurroundings()
+		}))
+	})
+}
 
-		for g.Level.At(g.Player.X, g.Player.Y).Type() != TileFloor {
-			g.Player.X = rand.Intn(g.Level.Width())
-			g.Player.Y = rand.Intn(g.Level.Height())
-		}
-	} else {
-		g.Level = prev
+// OnWalk is a callback which is fired when the tile is stepped on by the player
+func (f *TrapTile) OnWalk(g *Game) {
+	if f.Disarmed {
+		return
 	}
+
+	f.Hidden = false
+	g.UI.SetMinibuf(f.trigger(g), time.Second*4)
 }
 
 // OnInteract() definitions
@@ -238,22 +342,66 @@ func (f *ChestTile) OnInteract(x, y int, g *Game) {
 		return
 	}
 
-	g.Player.Money += rand.Intn(100) + 50
-	g.Player.Experience += rand.Intn(10) + 5
+	table := "chest"
+	if f.Rare {
+		table = "rare-chest"
+	}
 
 	f.Open = true
+	g.PlayAnimation(sparkleAnimation(x, y))
+
+	g.UI.SetMinibuf(g.giveLoot("in the chest", x, y, RollLoot(g.Known, table, g.Level.Depth)), time.Second*4)
 }
 
-// OnInteract is a callback which is fired when the tile is interacted with by the player
+// under returns the tile which is left behind when the box moves.
+func (f *BoxTile) under() Tile {
+	if f.Under != nil {
+		return f.Under
+	}
+
+	return &FloorTile{}
+}
+
+// OnInteract is a callback which is fired when the tile is interacted with by the player.
+// The box is pushed away from the player, and if there's something in the way, it's
+// smashed open instead.
 func (f *BoxTile) OnInteract(x, y int, g *Game) {
 	px, py := g.Player.X, g.Player.Y
 	dx, dy := x-px, y-py
 	nx, ny := x+dx, y+dy
 
-	if t := g.Level.At(nx, ny); t.Type() == TileFloor && time.Now().Sub(g.LastMove).Seconds() > 0.08 {
-		g.Level.Set(x, y, &FloorTile{})
-		g.Level.Set(nx, ny, &BoxTile{})
-		g.Player.X = x
-		g.Player.Y = y
+	if time.Now().Sub(g.LastMove).Seconds() <= 0.08 {
+		return
+	}
+
+	if t := g.Level.At(nx, ny); walkableFloor(t) && g.Level.ActorAt(nx, ny) == nil && len(g.Level.ItemsAt(nx, ny)) == 0 {
+		g.Level.Set(x, y, f.under())
+		g.Level.Set(nx, ny, &BoxTile{Under: t})
+		g.Player.walk(dx, dy)
+		return
+	}
+
+	g.Level.Set(x, y, f.under())
+	g.UI.SetMinibuf("You smash the box. "+g.giveLoot("in the wreckage", x, y, RollLoot(g.Known, "box", g.Level.Depth)), time.Second*4)
+}
+
+// OnInteract is a callback which is fired when the tile is interacted with by the player
+func (f *TrapTile) OnInteract(x, y int, g *Game) {
+	if f.Hidden || f.Disarmed {
+		return
 	}
+
+	if rand.Float64() < Conf.DisarmChance+float64(g.Player.Effective().Magic)*0.05 {
+		f.Disarmed = true
+		g.UI.SetMinibuf("You carefully disarm the "+trapNames[f.Kind]+".", time.Second*4)
+		g.Player.GainExperience(2 + g.Level.Depth)
+		return
+	}
+
+	g.UI.SetMinibuf("You fumble with the "+trapNames[f.Kind]+" and set it off! "+f.trigger(g), time.Second*4)
+}
+
+// OnInteract is a callback which is fired when the tile is interacted with by the player
+func (f *MerchantTile) OnInteract(x, y int, g *Game) {
+	f.trade(g)
 }
@@ -0,0 +1,138 @@
+package lib
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// The set of trap kinds
+const (
+	_ int = iota
+
+	TrapSpike
+	TrapTeleport
+	TrapAlarm
+	TrapGas
+)
+
+// alarmReputation is how much reputation the player loses with every
+// merchant on the level when they set off an alarm trap.
+const alarmReputation = 2
+
+var trapNames = map[int]string{
+	TrapSpike:    "spike trap",
+	TrapTeleport: "teleport trap",
+	TrapAlarm:    "alarm trap",
+	TrapGas:      "gas trap",
+}
+
+// trapChance returns the chance that a floor tile at the given depth will
+// have a trap placed on it. Deeper levels have more traps.
+func trapChance(depth int) float64 {
+	return Conf.TrapChance * (1 + Conf.TrapDepthScale*float64(depth-1))
+}
+
+// randomTrap makes a new hidden trap of a random kind.
+func randomTrap() *TrapTile {
+	return &TrapTile{
+		Kind:   rand.Intn(len(trapNames)) + 1,
+		Hidden: true,
+	}
+}
+
+// walkableFloor checks whether a tile is floor as far as the player can
+// tell, which includes the traps they haven't found yet. Anything which
+// picks a place on the floor should use it, so that it doesn't give away
+// where the hidden traps are by avoiding them.
+func walkableFloor(t Tile) bool {
+	if trap, ok := t.(*TrapTile); ok {
+		return trap.Hidden
+	}
+
+	return t.Type() == TileFloor
+}
+
+// trigger sets off a trap on the player, returning a message saying what
+// happened.
+func (f *TrapTile) trigger(g *Game) string {
+	depth := g.Level.Depth
+
+	switch f.Kind {
+	case TrapSpike:
+		damage := 5 + depth*2 + rand.Intn(5)
+		g.Player.Damage(damage, "a spike trap")
+		return fmt.Sprintf("Spikes shoot out of the floor! You take %d damage.", damage)
+
+	case TrapTeleport:
+		for {
+			x, y := rand.Intn(g.Level.Width()), rand.Intn(g.Level.Height())
+			if walkableFloor(g.Level.At(x, y)) && g.Level.ActorAt(x, y) == nil {
+				g.Player.X, g.Player.Y = x, y
+				break
+			}
+		}
+
+		return "The world twists around you, and you find yourself somewhere else."
+
+	case TrapAlarm:
+		merchants := 0
+
+		for y := 0; y < g.Level.Height(); y++ {
+			for x := 0; x < g.Level.Width(); x++ {
+				if m, ok := g.Level.At(x, y).(*MerchantTile); ok {
+					m.changeReputation(-alarmReputation)
+					merchants++
+				}
+			}
+		}
+
+		if merchants == 0 {
+			return "A deafening alarm rings out across the level, but there's nobody around to hear it."
+		}
+
+		return "A deafening alarm rings out across the level! The merchants won't thank you for the racket, and their prices go up."
+
+	case TrapGas:
+		g.Player.Effects.Add(EffectPoison, 5+depth, 1+depth/3)
+		g.Player.Effects.Add(EffectConfusion, 5, 0)
+		return "A cloud of foul gas bursts out of the floor. You feel sick and dizzy."
+	}
+
+	return ""
+}
+
+// Search makes the player search the tiles around them for hidden traps,
+// which takes up a turn.
+func (p *Player) Search() {
+	found := 0
+
+	for y := p.Y - 1; y <= p.Y+1; y++ {
+		for x := p.X - 1; x <= p.X+1; x++ {
+			if x < 0 || y < 0 || x >= p.Game.Level.Width() || y >= p.Game.Level.Height() {
+				continue
+			}
+
+			trap, ok := p.Game.Level.At(x, y).(*TrapTile)
+			if !ok || !trap.Hidden {
+				continue
+			}
+
+			if rand.Float64() < Conf.SearchChance+float64(p.Effective().Magic)*0.05 {
+				trap.Hidden = false
+				found++
+			}
+		}
+	}
+
+	switch found {
+	case 0:
+		p.Game.UI.SetMinibuf("You search around you, but don't find anything.", time.Second*4)
+	case 1:
+		p.Game.UI.SetMinibuf("You search around you and find a trap!", time.Second*4)
+	default:
+		p.Game.UI.SetMinibuf(fmt.Sprintf("You search around you and find %d traps!", found), time.Second*4)
+	}
+
+	p.Game.EndTurn()
+}
@@ -0,0 +1,227 @@
+package lib
+
+import (
+	"image"
+	"time"
+)
+
+// TravelDelay is how long the player waits between steps when they're
+// travelling somewhere, so they can see where they're going.
+const TravelDelay = time.Millisecond * 60
+
+// A journey is a path the player is travelling along, one step at a time.
+type journey struct {
+	path   []image.Point
+	level  *Map
+	health int
+
+	// next is when the player can take their next step.
+	next time.Time
+
+	// seen stores the actors which were in sight when the journey started.
+	// If any others come into sight, the player stops.
+	seen map[*Actor]bool
+}
+
+// FindPath finds the shortest path from (x1, y1) to (x2, y2) through tiles
+// the player has explored, avoiding obstacles, monsters and any traps they
+// know about. The end of the path doesn't have to be walkable itself. It
+// returns the tiles along the path, not including the start, or nil if
+// there isn't one.
+func (m *Map) FindPath(x1, y1, x2, y2 int) []image.Point {
+	var (
+		start = image.Pt(x1, y1)
+		goal  = image.Pt(x2, y2)
+		prev  = map[image.Point]image.Point{start: start}
+		queue = []image.Point{start}
+		dirs  = []image.Point{{0, -1}, {1, 0}, {0, 1}, {-1, 0}}
+	)
+
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+
+		if cur == goal {
+			path := []image.Point{}
+			for ; cur != start; cur = prev[cur] {
+				path = append([]image.Point{cur}, path...)
+			}
+
+			return path
+		}
+
+		for _, d := range dirs {
+			next := cur.Add(d)
+
+			if _, ok := prev[next]; ok || (next != goal && !m.walkable(next.X, next.Y)) {
+				continue
+			}
+
+			prev[next] = cur
+			queue = append(queue, next)
+		}
+	}
+
+	return nil
+}
+
+// walkable checks whether the player would be happy to walk through the
+// tile at (x, y) on their way somewhere.
+func (m *Map) walkable(x, y int) bool {
+	if x < 0 || y < 0 || x >= m.Width() || y >= m.Height() || !m.Explored(x, y) {
+		return false
+	}
+
+	t := m.At(x, y)
+
+	if trap, ok := t.(*TrapTile); ok && !trap.Hidden && !trap.Disarmed {
+		return false
+	}
+
+	return t.Passable() && m.ActorAt(x, y) == nil
+}
+
+// Click does whatever makes sense for the player to do when the tile at
+// (x, y) is clicked on. Clicking on the player picks up what they're
+// standing on, clicking next to them interacts with that tile (or walks
+// onto it), and clicking further away travels there.
+func (p *Player) Click(x, y int) {
+	p.StopTravelling()
+
+	dx, dy := x-p.X, y-p.Y
+
+	switch {
+	case dx == 0 && dy == 0:
+		p.PickUp()
+
+	case abs(dx)+abs(dy) == 1:
+		p.Direction = directionOf(dx, dy)
+
+		if p.Game.Level.ActorAt(x, y) != nil || !p.Game.Level.At(x, y).Passable() {
+			p.Interact()
+		} else {
+			p.Move(dx, dy)
+		}
+
+	default:
+		p.TravelTo(x, y)
+	}
+}
+
+// TravelTo starts the player walking to (x, y), one step at a time. They
+// stop if anything interesting happens on the way.
+func (p *Player) TravelTo(x, y int) {
+	path := p.Game.Level.FindPath(p.X, p.Y, x, y)
+	if path == nil || !p.Game.Level.Explored(x, y) {
+		p.Game.UI.SetMinibuf("You don't know a way there.", time.Second*4)
+		return
+	}
+
+	// If there's something in the way at the end, such as a merchant, the
+	// player stops next to it.
+	if !p.Game.Level.walkable(x, y) {
+		path = path[:len(path)-1]
+	}
+
+	if len(path) == 0 {
+		return
+	}
+
+	p.journey = &journey{
+		path:   path,
+		level:  p.Game.Level,
+		health: p.Health,
+		seen:   p.visibleActors(),
+	}
+
+	p.TravelStep()
+}
+
+// Travelling checks whether the player is on their way somewhere.
+func (p *Player) Travelling() bool {
+	return p.journey != nil
+}
+
+// StopTravelling stops the player where they are.
+func (p *Player) StopTravelling() {
+	p.journey = nil
+}
+
+// TravelStep takes the next step of the player's journey, if they've
+// waited long enough since the last one. The main loop has to keep waking
+// up every TravelDelay while they're travelling. The player waits while an
+// animation is blocking them, and carries on once it's finished. They stop
+// if anyone gets in their way, rather than attacking them.
+func (p *Player) TravelStep() {
+	j := p.journey
+	if j == nil || time.Now().Before(j.next) || p.Game.AnimationBlocking() {
+		return
+	}
+
+	next := j.path[0]
+	j.path = j.path[1:]
+
+	if a := p.Game.Level.ActorAt(next.X, next.Y); a != nil {
+		p.Game.UI.SetMinibuf("The "+a.Name()+" is in the way, so you stop.", time.Second*4)
+		p.StopTravelling()
+		return
+	}
+
+	dx, dy := next.X-p.X, next.Y-p.Y
+	if abs(dx)+abs(dy) != 1 {
+		p.StopTravelling()
+		return
+	}
+
+	p.Direction = directionOf(dx, dy)
+	p.Move(dx, dy)
+
+	switch {
+	case len(j.path) == 0,
+		p.X != next.X || p.Y != next.Y,
+		p.Game.Level != j.level,
+		p.Game.Dialog() != nil,
+		p.Health < j.health,
+		len(p.Game.Level.ItemsAt(p.X, p.Y)) > 0:
+		p.StopTravelling()
+		return
+	}
+
+	for a := range p.visibleActors() {
+		if !j.seen[a] {
+			p.Game.UI.SetMinibuf("You see the "+a.Name()+" and stop.", time.Second*4)
+			p.StopTravelling()
+			return
+		}
+	}
+
+	j.next = time.Now().Add(TravelDelay)
+}
+
+// visibleActors returns the set of actors the player can see.
+func (p *Player) visibleActors() map[*Actor]bool {
+	seen := make(map[*Actor]bool)
+
+	for _, a := range p.Game.Level.Actors {
+		if p.Game.Level.CanSee(p.X, p.Y, a.X, a.Y, p.viewRadius()) {
+			seen[a] = true
+		}
+	}
+
+	return seen
+}
+
+// directionOf returns the direction the player faces when moving by
+// (dx, dy).
+func directionOf(dx, dy int) int {
+	switch {
+	case dy < 0:
+		return 0
+	case dx > 0:
+		return 1
+	case dy > 0:
+		return 2
+	default:
+		return 3
+	}
+}
@@ -2,7 +2,10 @@ package lib
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/nsf/termbox-go"
 )
@@ -13,117 +16,261 @@ type UI struct {
 	Game           *Game
 	Minibuf        string
 	MinibufTimeout time.Time
+
+	// Messages stores every message which has been shown in the
+	// mini-buffer, oldest first.
+	Messages []string
+
+	// Hover describes the tile under the mouse cursor.
+	Hover string
 }
 
-// Render renders the UI to the screen, relative to the given coords.
-func (u *UI) Render(x, y int) {
-	fg, bg := termbox.ColorDefault, termbox.ColorDefault
-
-	writeText(x, y+0, -1, "      x: %d", fg, bg, u.Game.Player.X)
-	writeText(x, y+1, -1, "      y: %d", fg, bg, u.Game.Player.Y)
-	writeText(x, y+2, -1, "  depth: %d", fg, bg, u.Game.Level.Depth)
-	writeText(x, y+4, -1, " health: ^r%d%%^!", fg, bg, u.Game.Player.Health)
-	writeText(x, y+5, -1, "  money: ^g£%d^!", fg, bg, u.Game.Player.Money)
-	writeText(x, y+6, -1, "     xp: ^y%d^!", fg, bg, u.Game.Player.Experience)
-	writeText(x, y+7, -1, " attack: ^c%d^!", fg, bg, u.Game.Player.Attack)
-	writeText(x, y+8, -1, "defense: ^w%d^!", fg, bg, u.Game.Player.Defense)
-	writeText(x, y+9, -1, "  magic: ^m%d^!", fg, bg, u.Game.Player.Magic)
-
-	fg = 0x09
-	writeText(x, y+12, -1, "^wESC^! to exit the game", fg, bg)
-	writeText(x, y+13, -1, "^wQ^! to exit to the menu", fg, bg)
-	writeText(x, y+14, -1, "^w▲▼◀▶^! to move", fg, bg)
-	writeText(x, y+15, -1, "^wIJKL^! to turn on the spot", fg, bg)
-	writeText(x, y+16, -1, "^wSPACE^! to shoot", fg, bg)
-	writeText(x, y+17, -1, "^wS^! to interact with a tile", fg, bg)
-	writeText(x, y+18, -1, "^wD^! to inspect a tile", fg, bg)
+// Render renders the panels around the map to the screen, wherever the
+// layout puts them.
+func (u *UI) Render(l Layout) {
+	u.renderStats(l.Stats)
+
+	if !l.Minimap.Empty() {
+		u.Game.renderMinimap(l.Minimap.X, l.Minimap.Y, 2, 4, 1)
+	}
+
+	if !l.Hints.Empty() {
+		u.renderHints(l.Hints)
+	}
 
 	if time.Now().After(u.MinibufTimeout) {
 		u.Minibuf = ""
 	}
 
-	if len(u.Minibuf) > 0 {
-		fg = termbox.ColorDefault
-		w, _ := termbox.Size()
-		writeText(x, y+21, w-3, u.Minibuf, fg, bg)
+	u.renderLog(l.Log)
+}
+
+// renderStats draws the player's stats, leaving off the rows at the bottom
+// if they don't fit.
+func (u *UI) renderStats(r Rect) {
+	var (
+		fg, bg     = termbox.ColorDefault, termbox.ColorDefault
+		x, y       = r.X, r.Y
+		p          = u.Game.Player
+		have, need = p.LevelProgress()
+		base, eff  = p.Base(), p.Effective()
+	)
+
+	rows := []string{
+		fmt.Sprintf("      x: %d", p.X),
+		fmt.Sprintf("      y: %d", p.Y),
+		fmt.Sprintf("  depth: %d", u.Game.Level.Depth),
+		fmt.Sprintf("   turn: %d", u.Game.Turn),
+		fmt.Sprintf(" health: ^r%d/%d^!%s", p.Health, eff.Health, statDelta(base.Health, eff.Health)),
+		fmt.Sprintf("  money: ^g£%d^!", p.Money),
+		fmt.Sprintf("  level: ^b%d^!", p.Level),
+		fmt.Sprintf("     xp: ^y%d/%d^! %s", have, need, progressBar(have, need, 10)),
+		fmt.Sprintf(" attack: ^c%d^!%s", eff.Attack, statDelta(base.Attack, eff.Attack)),
+		fmt.Sprintf("defense: ^w%d^!%s", eff.Defense, statDelta(base.Defense, eff.Defense)),
+		fmt.Sprintf("  magic: ^m%d^!%s", eff.Magic, statDelta(base.Magic, eff.Magic)),
+		fmt.Sprintf("  speed: ^y%+d^!%s", eff.Speed, statDelta(base.Speed, eff.Speed)),
+	}
+
+	for i, row := range rows {
+		if i < r.H {
+			writeText(x, y+i, -1, "%s", fg, bg, row)
+		}
+	}
+
+	if r.H > len(rows) {
+		p.Hunger().Render(p.Effects.Render(x, y+len(rows)), y+len(rows))
+	}
+
+	if r.H > len(rows)+2 {
+		u.renderBossBar(x, y+len(rows)+1)
+	}
+}
+
+// renderHints draws a description of whatever's under the mouse, and then
+// the things the player can do right now.
+func (u *UI) renderHints(r Rect) {
+	var (
+		fg, bg = termbox.ColorDefault, termbox.ColorDefault
+		y      = r.Y
+	)
+
+	if u.Hover != "" {
+		if _, h := measureText(r.W, "%s", u.Hover); h < r.H {
+			writeText(r.X, y, r.X+r.W-1, "%s", fg, bg, u.Hover)
+			y += h + 1
+		}
+	}
+
+	fg = CurrentTheme.Fg("dim")
+	for _, hint := range u.Game.Player.Hints() {
+		if y >= r.Y+r.H {
+			break
+		}
+
+		writeText(r.X, y, -1, "%s", fg, bg, hint)
+		y++
+	}
+}
+
+// renderLog draws the most recent messages which fit, oldest first. The
+// message in the mini-buffer stands out from the older ones.
+func (u *UI) renderLog(r Rect) {
+	if r.Empty() {
+		return
+	}
+
+	var (
+		bg    = termbox.ColorDefault
+		y     = r.Y + r.H
+		first = len(u.Messages)
+	)
+
+	for first > 0 {
+		_, h := measureText(r.W, "%s", u.Messages[first-1])
+		if y-h < r.Y {
+			break
+		}
+
+		y -= h
+		first--
+	}
+
+	for i := first; i < len(u.Messages); i++ {
+		fg := CurrentTheme.Fg("dim")
+		if i == len(u.Messages)-1 && u.Minibuf != "" {
+			fg = termbox.ColorDefault
+		}
+
+		writeText(r.X, y, r.X+r.W-1, "%s", fg, bg, u.Messages[i])
+
+		_, h := measureText(r.W, "%s", u.Messages[i])
+		y += h
 	}
 }
 
 // SetMinibuf sets the UI's mini-buffer for a certain amount of time.
 func (u *UI) SetMinibuf(text string, duration time.Duration) {
 	u.Minibuf = text
+	u.Messages = append(u.Messages, text)
+
+	if Conf.Narrate {
+		u.Game.narrator().write(text)
+	}
 	u.MinibufTimeout = time.Now().Add(duration)
 }
 
-func writeText(sx, sy, wx int, text string, fg, bg termbox.Attribute, args ...interface{}) {
-	dfg := fg
-	x := sx
-	y := sy
-
-	str := []rune(fmt.Sprintf(text, args...))
-
-	for i := 0; i < len(str); i++ {
-		ch := rune(str[i])
-		if ch == '\n' {
-			x = sx
-			y++
-		} else if ch == '\t' {
-			x += 4
-			continue
-		} else if ch == '^' {
-			if i+1 >= len(str) || i+1 < 0 {
-				continue
-			}
-
-			switch str[i+1] {
-			case 'b':
-				fg = termbox.ColorBlue
-			case 'c':
-				fg = termbox.ColorCyan
-			case '!':
-				fg = dfg
-			case 'g':
-				fg = termbox.ColorGreen
-			case 'm':
-				fg = termbox.ColorMagenta
-			case 'r':
-				fg = termbox.ColorRed
-			case 'w':
-				fg = termbox.ColorWhite
-			case 'y':
-				fg = termbox.ColorYellow
-			case 'B':
-				fg |= termbox.AttrBold
-			}
-
-			i++
+var colourNames = map[string]termbox.Attribute{
+	"default":   termbox.ColorDefault,
+	"black":     termbox.ColorBlack,
+	"red":       termbox.ColorRed,
+	"green":     termbox.ColorGreen,
+	"yellow":    termbox.ColorYellow,
+	"blue":      termbox.ColorBlue,
+	"magenta":   termbox.ColorMagenta,
+	"cyan":      termbox.ColorCyan,
+	"white":     termbox.ColorWhite,
+	"bold":      termbox.AttrBold,
+	"underline": termbox.AttrUnderline,
+	"reverse":   termbox.AttrReverse,
+}
 
+// parseColour parses a colour from a config file. A colour is a list of
+// words separated by spaces, each of which is either the name of a colour
+// or attribute (e.g. "bold red") or a number (e.g. "0x0d"). An empty string
+// is the default colour.
+func parseColour(s string) (termbox.Attribute, error) {
+	var attr termbox.Attribute
+
+	for _, word := range strings.Fields(s) {
+		if a, ok := colourNames[strings.ToLower(word)]; ok {
+			attr |= a
 			continue
 		}
 
-		for x > wx && wx >= 0 {
-			x = sx
-			y++
+		n, err := strconv.ParseUint(word, 0, 16)
+		if err != nil {
+			return 0, fmt.Errorf("invalid colour %q", word)
 		}
 
-		termbox.SetCell(x, y, ch, fg, bg)
-		x++
+		attr |= termbox.Attribute(n)
+	}
+
+	return attr, nil
+}
+
+// progressBar makes a bar of the given width showing how close have is
+// to need.
+func progressBar(have, need, width int) string {
+	filled := 0
+	if need > 0 {
+		filled = have * width / need
+	}
+
+	if filled > width {
+		filled = width
 	}
+
+	return "[^y" + strings.Repeat("=", filled) + "^!" + strings.Repeat(" ", width-filled) + "]"
 }
 
-func delayText(x, y int, delay time.Duration, text string, fg, bg termbox.Attribute, stop chan bool, args ...interface{}) {
-	go func() {
-		str := fmt.Sprintf(text, args...)
+// statDelta makes the text shown after a stat in the sidebar when its
+// effective value is different from its base value: the base value, and
+// the difference in green if it's a bonus or red if it's a penalty.
+func statDelta(base, effective int) string {
+	switch {
+	case effective > base:
+		return fmt.Sprintf(" (%d^g+%d^!)", base, effective-base)
+	case effective < base:
+		return fmt.Sprintf(" (%d^r-%d^!)", base, base-effective)
+	default:
+		return ""
+	}
+}
 
-		for i := 0; i < len(str); i++ {
-			if stop != nil && len(stop) > 0 {
-				break
-			}
+// writeText draws some text at (sx, sy), with any codes in it (see
+// markup.go) changing its style. If wx isn't negative, the text is wrapped
+// so that it doesn't go past column wx.
+func writeText(sx, sy, wx int, text string, fg, bg termbox.Attribute, args ...interface{}) {
+	width := 0
+	if wx >= 0 {
+		width = wx - sx + 1
+	}
+
+	cells, _, _ := layoutText(parseMarkup(fmt.Sprintf(text, args...), fg, bg), width)
+
+	for _, c := range cells {
+		setCell(sx+c.X, sy+c.Y, c.Ch, c.Style.Fg, c.Style.Bg)
+	}
+}
 
-			termbox.Clear(fg, bg)
-			writeText(x, y, -1, str[:i+1], fg, bg)
-			termbox.Flush()
-			time.Sleep(delay)
+// drawGlyph draws a glyph at (x, y) exactly as it's written, so glyphs such
+// as "^ " aren't taken as markup codes.
+func drawGlyph(x, y int, glyph string, fg, bg termbox.Attribute) {
+	for _, ch := range glyph {
+		setCell(x, y, ch, fg, bg)
+		x++
+	}
+}
+
+// asciiRunes are the plain ASCII characters drawn instead of the other
+// characters the game uses, when the config asks for ASCII.
+var asciiRunes = map[rune]rune{
+	'▲': '^', '▼': 'v', '◀': '<', '▶': '>',
+	'─': '-', '│': '|', '┌': '+', '┐': '+', '└': '+', '┘': '+',
+	'░': '.', '▓': '#', '█': '#', '▨': 'x',
+	'£': '$', 'Ω': 'O',
+}
+
+// setCell draws a character on the screen, converting it and its colours
+// into ones the terminal can show.
+func setCell(x, y int, ch rune, fg, bg termbox.Attribute) {
+	if Conf.ASCII && ch > unicode.MaxASCII {
+		if r, ok := asciiRunes[ch]; ok {
+			ch = r
+		} else {
+			ch = '?'
 		}
-	}()
+	}
+
+	termbox.SetCell(x, y, ch, outputColour(fg, true), outputColour(bg, false))
 }
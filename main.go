@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"math/rand"
 	"os"
 	"time"
 
@@ -14,6 +15,10 @@ var (
 )
 
 func main() {
+	if err := lib.Setup("cfg.yaml"); err != nil {
+		log.Fatalf("error loading the config: %v", err)
+	}
+
 	err := termbox.Init()
 	if err != nil {
 		panic(err)
@@ -32,77 +37,149 @@ func main() {
 		log.Println("closing game")
 	}()
 
-	termbox.SetOutputMode(termbox.Output256)
+	lib.SetOutputMode()
 	termbox.SetInputMode(termbox.InputEsc | termbox.InputMouse)
 
+	// termbox only asks for mouse movement while a button is held down, so
+	// ask for all of it, to be able to describe whatever's under the mouse.
+	os.Stdout.WriteString("\x1b[?1003h")
+	defer os.Stdout.WriteString("\x1b[?1003l")
+
 	lib.SpawnWorkers()
 
-	game = &lib.Game{
+	for lib.Menu() {
+		newGame()
+
+		if !play() {
+			break
+		}
+	}
+}
+
+// newGame sets up a brand new run, with a freshly seeded random number
+// generator.
+func newGame() {
+	seed := time.Now().UnixNano()
+	rand.Seed(seed)
+
+	g := &lib.Game{
 		Level:    lib.MakeMap(1),
 		LastMove: time.Now(),
+		Seed:     seed,
+		Known:    lib.NewKnowledge(lib.Items),
 	}
 
-	game.Player = lib.NewPlayer(game)
+	g.Player = lib.NewPlayer(g)
 
-	game.UI = &lib.UI{
-		Game: game,
+	g.UI = &lib.UI{
+		Game: g,
 	}
 
-	go func() {
-		for {
-			redraw()
-			time.Sleep(time.Second)
-		}
+	game = g
+	log.Printf("starting a new run with seed %d", seed)
+}
+
+// play runs the main loop for the current game. It returns true if the
+// player died, and false if they quit.
+func play() bool {
+	// The main loop is woken up every second to keep the screen up to
+	// date, for every frame while there are animations playing, and for
+	// every step while the player is travelling. Only the main loop
+	// touches the game, so nothing else can draw it while it's changing.
+	wake := time.AfterFunc(time.Second, termbox.Interrupt)
+
+	// dying is set once the player has died, while they're being shown
+	// the death screen.
+	dying := false
+
+	defer func() {
+		wake.Stop()
+		game.Narrator.Close()
+		game = nil
 	}()
 
-mainloop:
+	redraw()
+
 	for {
 		switch evt := termbox.PollEvent(); evt.Type {
 		case termbox.EventKey:
-			if evt.Key == termbox.KeyEsc {
-				break mainloop
+			game.Player.StopTravelling()
+			action := lib.ActionFor(evt.Ch, evt.Key)
+
+			// While a blocking animation is playing, the only thing the
+			// player can do is skip it.
+			if game.AnimationBlocking() {
+				if action != nil && action.Name == "skip" {
+					action.Run(game)
+				}
+
+				break
+			}
+
+			if game.Dialog() != nil {
+				game.HandleDialogKey(evt.Ch, evt.Key)
+				break
 			}
 
-			handleKey(evt.Ch, evt.Key)
+			if action != nil {
+				if action.Run == nil {
+					return false
+				}
+
+				action.Run(game)
+			}
+
+		case termbox.EventMouse:
+			if game.Dialog() == nil {
+				handleMouse(evt)
+			}
+
+		case termbox.EventInterrupt:
+			game.UpdateAnimations()
+			game.Player.TravelStep()
+		}
+
+		game.UpdateAnimations()
+
+		if game.Over {
+			return true
+		}
+
+		if game.Player.Dead() && !dying {
+			log.Printf("player died: killed by %s", game.Player.CauseOfDeath)
+			lib.Die(game)
+			dying = true
+		}
+
+		switch {
+		case game.Animating():
+			wake.Reset(lib.FrameDuration())
+		case game.Player.Travelling():
+			wake.Reset(lib.TravelDelay)
+		default:
+			wake.Reset(time.Second)
 		}
 
 		redraw()
 	}
 }
 
-func handleKey(ch rune, key termbox.Key) {
-	switch ch {
-	case 's', 'S':
-		game.Player.Interact()
-	case 'd', 'D':
-		game.Player.Inspect()
-	case 'i', 'I':
-		game.Player.Direction = 0
-	case 'j', 'J':
-		game.Player.Direction = 3
-	case 'k', 'K':
-		game.Player.Direction = 2
-	case 'l', 'L':
-		game.Player.Direction = 1
+// handleMouse describes the tile under the mouse as it moves, and lets the
+// player click on tiles to travel to them or interact with them.
+func handleMouse(evt termbox.Event) {
+	x, y, ok := game.ScreenToMap(evt.MouseX, evt.MouseY)
+	if !ok {
+		game.UI.Hover = ""
+		return
 	}
 
-	if time.Now().Sub(game.LastMove).Seconds() > 0.08 {
-		switch key {
-		case termbox.KeyArrowLeft:
-			game.Player.Move(-1, 0)
-			game.Player.Direction = 3
-		case termbox.KeyArrowRight:
-			game.Player.Move(1, 0)
-			game.Player.Direction = 1
-		case termbox.KeyArrowUp:
-			game.Player.Move(0, -1)
-			game.Player.Direction = 0
-		case termbox.KeyArrowDown:
-			game.Player.Move(0, 1)
-			game.Player.Direction = 2
-		}
+	if evt.Mod&termbox.ModMotion != 0 {
+		game.UI.Hover = game.Describe(x, y)
+		return
+	}
 
-		game.LastMove = time.Now()
+	if evt.Key == termbox.MouseLeft && !game.AnimationBlocking() {
+		game.Player.Click(x, y)
 	}
 }
 